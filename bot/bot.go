@@ -2,23 +2,55 @@ package bot
 
 import (
 	"errors"
-	"log"
+	"net/http"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/marcozaccari/AssistantBot/i18n"
+	"github.com/marcozaccari/AssistantBot/logger"
+	"github.com/marcozaccari/AssistantBot/metrics"
 	"github.com/marcozaccari/AssistantBot/settings"
+	"github.com/marcozaccari/AssistantBot/storage"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 )
 
 const version = "1.0.2"
 
+// nome del bot come processore (e della sua sezione di configurazione)
+const botProcessorName = "Bot"
+
+// sottodirectory di default nella directory di configurazione dell'utente
+const defaultConfigAppName = "AssistantBot"
+
 // Bot - incapsula lo stack di Telegram
 type Bot struct {
+	// livello del logger di default (vedi SetLogger)
 	Debug   bool
 	Verbose bool
 
+	log     logger.Logger
+	logLock sync.Mutex
+
+	StrictConfig bool          // rifiuta le chiavi sconosciute nel file di configurazione
+	WatchConfig  time.Duration // se > 0 ricarica il file di configurazione quando viene modificato
+
+	// directory in cui cercare il file di configurazione se relativo (vedi
+	// settings.DefaultSearchPaths); ConfigAppName è la sottodirectory nella
+	// directory di configurazione dell'utente (es. ~/.config/AssistantBot)
+	ConfigSearchPaths []string
+	ConfigAppName     string
+
+	// se il file di configurazione non esiste viene creato con questo contenuto
+	// (di default DefaultConfigContent); nil = non viene creato
+	DefaultConfig []byte
+
+	// file dello stato di runtime (.db per bbolt, altrimenti JSON); se vuoto
+	// viene ricavato dal file di configurazione (es. settings.bot.state.db)
+	StateFilename string
+
 	username string
 	userID   int
 
@@ -26,45 +58,69 @@ type Bot struct {
 	configCtrl   *settings.Settings
 	configLoaded bool
 
-	config     configData
-	configLock sync.Mutex // serializza le scritture in config
+	config configData // accesso tramite configCtrl.View/Update
 
+	store     storage.Store
+	storeLock sync.Mutex
+
+	stateLock   sync.RWMutex // protegge ownerID e lookupUsers
+	ownerID     int
 	lookupUsers usersLookupMap
 
+	seenLock  sync.Mutex // protegge seenUsers
+	seenUsers map[int]*seenUser
+
 	sentMessages sentMessagesLookups
 
-	processors      []Processor
-	processorsNames []string
+	catalog *i18n.Catalog // messaggi tradotti (vedi RegisterTranslations)
+
+	processors  []*processorEntry // in ordine di dispatch (vedi RegisterProcessorWithOpt)
+	middlewares []Middleware
+
+	menuLock   sync.Mutex
+	menuSynced map[string]map[string]interface{} // menu dei comandi impostati (vedi SyncCommands)
 
 	silenceOn    bool
 	timerSilence *time.Timer
 
+	metricsRegistry *metrics.Registry
+	metrics         botMetrics
+	httpServer      *http.Server
+
+	statusLock sync.Mutex
+	status     runtimeStatus
+
+	stop     chan struct{} // chiuso da Stop
+	stopOnce sync.Once
+
+	apiClient *http.Client // client HTTP delle API di Telegram; nil = di default
+
 	Tgbot *tgbotapi.BotAPI
 }
 
 func (bot *Bot) initStack() error {
-	if bot.Verbose {
-		log.Printf("Init stack (telegram-bot-api)")
-	}
-	if bot.Debug {
-		log.Printf("SecureToken \"%s\"", bot.config.SecureToken)
+	bot.Logger().Info("Init stack (telegram-bot-api)")
+	// il token non viene mai riportato nei log, nemmeno in quelli della libreria
+	tgbotapi.SetLogger(stackLogger{bot})
+
+	client := bot.apiClient
+	if client == nil {
+		client = &http.Client{}
 	}
 
 	var err error
-	bot.Tgbot, err = tgbotapi.NewBotAPI(bot.config.SecureToken)
+	bot.Tgbot, err = tgbotapi.NewBotAPIWithClient(bot.getConfig().SecureToken, client)
 	if err != nil {
-		return errors.New("(stack) " + err.Error())
+		return errors.New("(stack) " + bot.redact(err.Error()))
 	}
 
 	//bot.Tgbot.Debug = true
 
-	if bot.Verbose {
-		bot.username = bot.Tgbot.Self.UserName
-		bot.userID = bot.Tgbot.Self.ID
-		log.Printf("(stack) Bot username \"%s\"", bot.Tgbot.Self.UserName)
-	}
+	bot.username = bot.Tgbot.Self.UserName
+	bot.userID = bot.Tgbot.Self.ID
+
+	bot.Logger().Info("Bot connected", "username", bot.Tgbot.Self.UserName, "user_id", bot.Tgbot.Self.ID)
 
-	bot.initUsers()
 	bot.initMessages()
 
 	return nil
@@ -74,15 +130,27 @@ func (bot *Bot) init(configFilename string) error {
 	var err error
 
 	bot.configs = make(map[string]interface{})
-	bot.processors = []Processor{}
+	bot.processors = []*processorEntry{}
+
+	bot.initMetrics()
+	bot.initTranslations()
+	bot.stop = make(chan struct{})
+
+	bot.ConfigAppName = defaultConfigAppName
+	bot.DefaultConfig = DefaultConfigContent
 
 	bot.config = configData{}
-	bot.RegisterProcessor("bot", bot, &bot.config)
+	bot.RegisterProcessor(botProcessorName, bot, &bot.config)
 
 	bot.configCtrl, err = settings.New(configFilename, bot.configs, bot.Verbose)
 	if err != nil {
 		return err
 	}
+	bot.configCtrl.EnvPrefix = configEnvPrefix
+	bot.configCtrl.OnChange = bot.onConfigChanged
+
+	// i vecchi file di configurazione contengono ancora owner e utenti
+	bot.configCtrl.RegisterMigration("Bot", 0, bot.migrateConfigV0)
 
 	return nil
 }
@@ -94,17 +162,29 @@ func (bot *Bot) Version() string {
 
 // RegisterProcessor aggiunge un processor al bot, estendendone le funzionalità
 func (bot *Bot) RegisterProcessor(name string, processor Processor, configData interface{}) {
+	bot.RegisterProcessorWithOpt(name, processor, configData, ProcessorOpt{})
+}
+
+// RegisterProcessorWithOpt - come RegisterProcessor, specificando priorità
+// ed eventualmente che il processore è un osservatore (vedi ProcessorOpt)
+func (bot *Bot) RegisterProcessorWithOpt(name string, processor Processor, configData interface{}, opt ProcessorOpt) {
 	name = strings.Title(name)
 
-	bot.processors = append(bot.processors, processor)
-	bot.processorsNames = append(bot.processorsNames, name)
+	bot.processors = append(bot.processors, &processorEntry{name: name, processor: processor, opt: opt})
+
+	// ordine effettivo: priorità decrescente, a parità l'ordine di registrazione
+	sort.SliceStable(bot.processors, func(i, j int) bool {
+		return bot.processors[i].opt.Priority > bot.processors[j].opt.Priority
+	})
 
 	if configData != nil {
 		bot.RegisterConfig(name, configData)
 	}
 }
 
-// Do - processa in modo bloccante le updates di Telegram.
+// Do - processa in modo bloccante le updates di Telegram, fino ad un errore
+// di un processore o all'invocazione di Stop (in tal caso ritorna nil).
+// All'uscita vengono arrestati i processori (Stopper) e rilasciate le risorse.
 func (bot *Bot) Do() error {
 	var err error
 	var offset int
@@ -116,12 +196,45 @@ func (bot *Bot) Do() error {
 		}
 	}
 
+	// fino all'avvio dei processori lo store aperto da loadState viene chiuso qui
+	// in caso di errore, poi da shutdown
+	err = bot.loadState()
+	if err != nil {
+		bot.closeStore()
+		return errors.New("(state) " + err.Error())
+	}
+
 	err = bot.initStack()
 	if err != nil {
+		bot.closeStore()
 		return err
 	}
 
-	if !bot.config.RecoverOldUpdates {
+	started, err := bot.startProcessors()
+	defer bot.shutdown(started)
+	if err != nil {
+		return err
+	}
+
+	// eventuali errori vengono soltanto registrati
+	bot.SyncCommands()
+
+	bot.statusLock.Lock()
+	bot.status.startTime = time.Now()
+	bot.statusLock.Unlock()
+
+	if addr := bot.getConfig().HTTPListen; addr != "" {
+		err = bot.startHTTP(addr)
+		if err != nil {
+			return errors.New("(http) " + err.Error())
+		}
+	}
+
+	if bot.WatchConfig > 0 {
+		bot.configCtrl.Watch(bot.WatchConfig)
+	}
+
+	if !bot.getConfig().RecoverOldUpdates {
 		offset = -1
 	}
 
@@ -130,32 +243,51 @@ func (bot *Bot) Do() error {
 
 	updates, err := bot.Tgbot.GetUpdatesChan(u)
 	if err != nil {
-		return errors.New("(stack) " + err.Error())
+		return errors.New("(stack) " + bot.redact(err.Error()))
 	}
 
-	log.Println("Listening for updates...")
-
-	// bloccante
-	for update := range updates {
-		// Delega le update ai processori nel modo più trasparente possibile.
-		// Il primo che processa interrompe la coda.
-		// L'ordine dei processori è inverso; l'ultimo, che è questo oggetto bot,
-		// parserà comandi e messaggi (richiamando a sua volta i rispettivi metodi
-		// dei processori) soltanto se nessuno ha già processato le update.
-		for i := len(bot.processors) - 1; i >= 0; i-- {
-			p := bot.processors[i]
-
-			processed, err := p.ProcessUpdate(update)
-			if err != nil {
-				return err
-			}
-			if processed {
-				break
-			}
+	bot.statusLock.Lock()
+	bot.status.updates = updates
+	bot.status.running = true
+	bot.statusLock.Unlock()
+
+	bot.Logger().Info("Listening for updates")
+
+	// bloccante, fino a Stop
+	for {
+		var update tgbotapi.Update
+
+		select {
+		case <-bot.stop:
+			return nil
+		case update = <-updates:
+		}
+
+		bot.metrics.updates.Inc()
+		bot.recordUpdate()
+		start := time.Now()
+
+		ctx := &Context{Bot: bot, Kind: DispatchUpdate, Update: update}
+		_, err := bot.dispatch(ctx, bot.dispatchUpdate)
+		if err != nil {
+			return err
 		}
+
+		bot.metrics.updateDuration.Observe(time.Since(start).Seconds())
 	}
+}
 
-	return nil
+// Delega le update ai processori nel modo più trasparente possibile.
+// Il primo che processa interrompe la coda.
+// Il bot è sempre l'ultimo: parserà comandi e messaggi (richiamando a sua volta
+// i rispettivi metodi dei processori) soltanto se nessuno ha già processato le update.
+func (bot *Bot) dispatchUpdate(ctx *Context) (bool, error) {
+	_, processed, err := bot.dispatchProcessors(bot.updateOrder(), DispatchUpdate, ctx,
+		func(p Processor) (bool, error) {
+			return p.ProcessUpdate(ctx.Update)
+		})
+
+	return processed, bot.redactError(err)
 }
 
 func NewBot(configFilename string, verbose bool, debug bool) *Bot {
@@ -1,8 +1,8 @@
 package bot
 
 import (
-	"fmt"
-	"log"
+	"html"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,11 +19,41 @@ const commandPing = "ping"
 
 const commandSilence = "silence"
 
+// elimina le risposte del bot
+const commandPurge = "purge"
+
+// numero di risposte eliminate da /purge senza parametri
+const purgeDefaultCount = 10
+
+// ricarica la configurazione
+const commandReload = "reload"
+
+// stato di funzionamento
+const commandStatus = "status"
+
+// informazioni sui processori
+const commandProcessor = "processor"
+
+// visualizza e modifica la configurazione
+const commandConfig = "config"
+
+// lingua delle risposte
+const commandLanguage = "language"
+
+// Help - help del bot nella lingua di default
 func (bot *Bot) Help() string {
-	return "/owner  Reset the owner\n" +
-		"/user  Users commands\n" +
-		"/silence [off]  Stop (or restart) messages autoparsing\n" +
-		"/ping  Test the bot\n"
+	return bot.LocalizedHelp(bot.catalog.DefaultLanguage())
+}
+
+// LocalizedHelp - help del bot nella lingua (vedi LocalizedHelper)
+func (bot *Bot) LocalizedHelp(lang string) string {
+	var help string
+	for _, command := range []string{superCommandOwner, commandUser, commandLanguage, commandSilence, commandPurge,
+		commandReload, commandConfig, commandProcessor, commandStatus, commandPing} {
+		help += bot.T(lang, "bot.help."+command)
+	}
+
+	return help
 }
 
 // Parsa un messaggio ottenendo comando e parametri.
@@ -78,8 +108,8 @@ func (bot *Bot) parseCommand(message *tgbotapi.Message) (command string, params
 		if b, newText := matchFirstWord("@" + bot.username); b {
 			isCommand = true
 			text = newText
-		} else if bot.config.CommandWord != "" {
-			if b, newText := matchFirstWord(bot.config.CommandWord); b {
+		} else if commandWord := bot.getConfig().CommandWord; commandWord != "" {
+			if b, newText := matchFirstWord(commandWord); b {
 				isCommand = true
 				text = newText
 			}
@@ -107,6 +137,26 @@ func (bot *Bot) parseCommand(message *tgbotapi.Message) (command string, params
 	return
 }
 
+// help dei processori attivi nella chat, nella lingua indicata
+func (bot *Bot) helpText(lang string, chatID int64) string {
+	var help string
+
+	disabled := bot.disabledProcessors(chatID)
+	for _, e := range bot.processors {
+		if disabled.has(e.name) {
+			continue
+		}
+
+		if localized, ok := e.processor.(LocalizedHelper); ok {
+			help += localized.LocalizedHelp(lang) + "\n"
+		} else {
+			help += e.processor.Help() + "\n"
+		}
+	}
+
+	return help
+}
+
 func (bot *Bot) ProcessCommand(handler MessageHandler, command string, params []string) (bool, error) {
 	switch command {
 
@@ -119,20 +169,17 @@ func (bot *Bot) ProcessCommand(handler MessageHandler, command string, params []
 		fallthrough
 
 	case "help":
-		var help string
-		for _, p := range bot.processors {
-			help += p.Help() + "\n"
-		}
+		help := bot.helpText(handler.Language, handler.ChatID)
 		opt := bot.NewMessageResponseOpt()
 		bot.SendMessageResponseToPrivate(handler, help, opt)
 
 	case superCommandOwner:
 		// reset owner
 		if len(params) > 0 {
-			if params[0] == bot.config.SecureToken {
+			if params[0] == bot.getConfig().SecureToken {
 				bot.resetOwner(handler.UserID, handler.Username, handler.ChatID)
 
-				text := "You are the owner of this bot, now"
+				text := bot.T(handler.Language, "bot.owner.reset")
 				opt := bot.NewMessageResponseOpt()
 				bot.SendMessageResponse(handler, text, opt)
 			}
@@ -141,16 +188,42 @@ func (bot *Bot) ProcessCommand(handler MessageHandler, command string, params []
 
 	case commandPing:
 		var text string
-		for i, p := range bot.processors {
-			text += bot.processorsNames[i] + " <code>" + p.Version() + "</code>\n"
+		for _, e := range bot.processors {
+			text += e.name + " <code>" + e.processor.Version() + "</code>\n"
 		}
+		text += "\n" + bot.T(handler.Language, "bot.ping.uptime") + " <code>" + bot.Status().Uptime + "</code>\n"
 
 		opt := bot.NewMessageResponseOpt()
+		opt.DeleteAfter = ephemeralDeleteAfter
 		bot.SendMessageResponse(handler, text, opt)
 
 	case commandSilence:
 		bot.processSilenceCommand(handler, params)
 
+	case commandPurge:
+		bot.processPurgeCommand(handler, params)
+		return true, nil
+
+	case commandReload:
+		bot.processReloadCommand(handler)
+		return true, nil
+
+	case commandProcessor:
+		bot.processProcessorCommand(handler, params)
+		return true, nil
+
+	case commandStatus:
+		bot.processStatusCommand(handler)
+		return true, nil
+
+	case commandConfig:
+		bot.processConfigCommand(handler, params)
+		return true, nil
+
+	case commandLanguage:
+		bot.processLanguageCommand(handler, params)
+		return true, nil
+
 	case commandUser:
 		err := bot.processUserCommand(handler, params)
 		if err != nil {
@@ -169,15 +242,15 @@ func (bot *Bot) processSilenceCommand(handler MessageHandler, params []string) {
 			bot.timerSilence.Stop()
 		}
 
-		text := "Silence mode off"
+		text := bot.T(handler.Language, "bot.silence.off")
 		opt := bot.NewMessageResponseOpt()
 		bot.SendMessageResponse(handler, text, opt)
 		return
 	}
 
-	mins := bot.config.SilenceTimeoutMins
+	mins := bot.getConfig().SilenceTimeoutMins
 	if mins == 0 {
-		mins = 30
+		mins = defaultSilenceTimeoutMins
 	}
 	timeout := time.Minute * time.Duration(mins)
 	bot.silenceOn = true
@@ -187,15 +260,75 @@ func (bot *Bot) processSilenceCommand(handler MessageHandler, params []string) {
 	} else {
 		endSilence := func() {
 			bot.silenceOn = false
-			if bot.Verbose {
-				log.Println("Silence mode off")
-			}
+			bot.Logger().Info("Silence mode off")
 		}
 
 		bot.timerSilence = time.AfterFunc(timeout, endSilence)
 	}
 
-	text := fmt.Sprintf("Silenced for <code>%v</code> minutes", mins)
+	text := bot.T(handler.Language, "bot.silence.on", mins)
+	opt := bot.NewMessageResponseOpt()
+	bot.SendMessageResponse(handler, text, opt)
+}
+
+func (bot *Bot) processPurgeCommand(handler MessageHandler, params []string) {
+	if handler.Group != groupOwner && handler.Group != groupAdmin {
+		bot.logNoPermission(handler, commandPurge)
+		return
+	}
+
+	var deleted int
+
+	if handler.ReplyMessageID > 0 && len(params) == 0 {
+		// risposta ad un messaggio: elimina le risposte del bot ad esso collegate,
+		// oppure il messaggio stesso se è del bot
+		if handler.ReplyUserID == bot.userID {
+			if bot.DeleteMessage(handler.ChatID, handler.ReplyMessageID) == nil {
+				deleted = 1
+			}
+		} else if bot.PurgeResponse(handler.ChatID, handler.ReplyMessageID) {
+			deleted = 1
+		}
+	} else {
+		n := purgeDefaultCount
+		if len(params) > 0 {
+			var err error
+			n, err = strconv.Atoi(params[0])
+			if err != nil || n <= 0 {
+				opt := bot.NewMessageResponseOpt()
+				opt.DeleteAfter = ephemeralDeleteAfter
+				bot.SendMessageResponse(handler, bot.T(handler.Language, "bot.purge.invalid"), opt)
+				return
+			}
+		}
+
+		deleted = bot.PurgeResponses(handler.ChatID, n)
+	}
+
+	text := bot.T(handler.Language, "bot.purge.deleted", deleted)
+	opt := bot.NewMessageResponseOpt()
+	opt.DeleteAfter = ephemeralDeleteAfter
+	bot.SendMessageResponse(handler, text, opt)
+}
+
+func (bot *Bot) processReloadCommand(handler MessageHandler) {
+	if handler.Group != groupOwner {
+		bot.logNoPermission(handler, commandReload)
+		return
+	}
+
+	var text string
+
+	changed, err := bot.ReloadConfig()
+	switch {
+	case err != nil:
+		text = bot.T(handler.Language, "bot.reload.failed", html.EscapeString(err.Error()))
+	case len(changed) == 0:
+		text = bot.T(handler.Language, "bot.reload.unchanged")
+	default:
+		text = bot.T(handler.Language, "bot.reload.changed", strings.Join(changed, ", "))
+	}
+
 	opt := bot.NewMessageResponseOpt()
 	bot.SendMessageResponse(handler, text, opt)
 }
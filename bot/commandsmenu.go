@@ -0,0 +1,374 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// CommandScope - chi vede un comando nel menu dei comandi di Telegram
+type CommandScope int
+
+const (
+	CommandScopeAll        CommandScope = iota // tutti, in ogni chat
+	CommandScopePrivate                        // chat private
+	CommandScopeGroups                         // gruppi
+	CommandScopeChatAdmins                     // amministratori dei gruppi
+	CommandScopeBotAdmins                      // owner e admin del bot, nella loro chat privata
+	CommandScopeOwner                          // owner del bot, nella sua chat privata
+)
+
+// CommandInfo - comando mostrato nel menu dei comandi di Telegram
+type CommandInfo struct {
+	Command      string            // senza "/": a-z, 0-9 e _, al massimo 32 caratteri
+	Description  string            // descrizione di default
+	Descriptions map[string]string // descrizioni tradotte per language_code (es. "it")
+	Scope        CommandScope
+}
+
+// CommandsDescriber - interfaccia opzionale dei processori che dichiarano i propri
+// comandi per il menu di Telegram; in sua assenza i comandi vengono ricavati
+// dalle righe di Help() nella forma "/comando parametri  descrizione"
+type CommandsDescriber interface {
+	Commands() []CommandInfo
+}
+
+// limiti di setMyCommands
+const (
+	maxMenuCommands          = 100
+	maxMenuDescriptionLength = 256
+)
+
+var validMenuCommand = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// comando nel formato di setMyCommands
+type menuCommand struct {
+	Command     string `json:"command"`
+	Description string `json:"description"`
+}
+
+// menu da impostare per uno scope di Telegram (BotCommandScope)
+type commandsMenu struct {
+	scope     map[string]interface{}
+	audiences []CommandScope
+	chatID    int64 // se != 0 vengono esclusi anche i processori disattivati nella chat
+}
+
+// chiave di un menu impostato (scope e lingua), per rimuovere quelli non più necessari
+func (menu commandsMenu) key(lang string) string {
+	scope, _ := json.Marshal(menu.scope)
+	return string(scope) + "|" + lang
+}
+
+// SyncCommands - imposta il menu dei comandi di Telegram (setMyCommands) a partire
+// dai comandi dichiarati dai processori (vedi CommandsDescriber), per ogni scope e
+// lingua; viene invocata da Do all'avvio e quando cambiano utenti o processori attivi.
+// Non fa nulla se ManualCommandsMenu è impostato.
+func (bot *Bot) SyncCommands() error {
+	if bot.Tgbot == nil || bot.getConfig().ManualCommandsMenu {
+		return nil
+	}
+
+	bot.menuLock.Lock()
+	defer bot.menuLock.Unlock()
+
+	infos := bot.commandsInfo()
+	langs := commandsLanguages(infos)
+
+	var firstErr error
+	synced := make(map[string]map[string]interface{}) // chiave -> scope
+
+	for _, menu := range bot.commandsMenus() {
+		disabled := bot.disabledProcessors(menu.chatID)
+
+		for _, lang := range langs {
+			key := menu.key(lang)
+			commands := menuCommands(infos, menu.audiences, disabled, lang)
+
+			err := bot.setMyCommands(menu.scope, lang, commands)
+			if err != nil {
+				bot.Logger().Warn("Cannot set commands menu", "scope", key, "err", bot.redactError(err))
+				if firstErr == nil {
+					firstErr = err
+				}
+				if scope, ok := bot.menuSynced[key]; ok {
+					synced[key] = scope
+				}
+				continue
+			}
+
+			synced[key] = menu.scope
+		}
+	}
+
+	// rimuove i menu impostati in precedenza non più necessari (es. admin rimossi)
+	for key, scope := range bot.menuSynced {
+		if _, ok := synced[key]; ok {
+			continue
+		}
+
+		lang := key[strings.LastIndex(key, "|")+1:]
+		err := bot.setMyCommands(scope, lang, nil)
+		if err != nil {
+			bot.Logger().Warn("Cannot delete commands menu", "scope", key, "err", bot.redactError(err))
+			synced[key] = scope // riprova alla prossima sincronizzazione
+		}
+	}
+
+	bot.menuSynced = synced
+
+	bot.Logger().Debug("Commands menu synced", "menus", len(synced))
+
+	return firstErr
+}
+
+// sincronizza il menu dei comandi in background
+func (bot *Bot) refreshCommands() {
+	if bot.Tgbot == nil {
+		return
+	}
+
+	go bot.SyncCommands()
+}
+
+// imposta (o rimuove, se commands è vuoto) il menu dei comandi dello scope
+func (bot *Bot) setMyCommands(scope map[string]interface{}, lang string, commands []menuCommand) error {
+	jsonScope, err := json.Marshal(scope)
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	params.Set("scope", string(jsonScope))
+	if lang != "" {
+		params.Set("language_code", lang)
+	}
+
+	endpoint := "deleteMyCommands"
+	if len(commands) > 0 {
+		jsonCommands, err := json.Marshal(commands)
+		if err != nil {
+			return err
+		}
+
+		params.Set("commands", string(jsonCommands))
+		endpoint = "setMyCommands"
+	}
+
+	resp, err := bot.Tgbot.MakeRequest(endpoint, params)
+	if err != nil {
+		return err
+	}
+	if !resp.Ok {
+		return errors.New(resp.Description)
+	}
+
+	return nil
+}
+
+// scope di Telegram da impostare. Telegram non unisce i menu: quello dello scope
+// più specifico sostituisce gli altri, quindi ognuno contiene tutti i comandi visibili.
+func (bot *Bot) commandsMenus() []commandsMenu {
+	scope := func(t string) map[string]interface{} {
+		return map[string]interface{}{"type": t}
+	}
+	chatScope := func(t string, chatID int64) map[string]interface{} {
+		return map[string]interface{}{"type": t, "chat_id": chatID}
+	}
+
+	menus := []commandsMenu{
+		{scope: scope("default"), audiences: []CommandScope{CommandScopeAll}},
+		{scope: scope("all_private_chats"), audiences: []CommandScope{CommandScopeAll, CommandScopePrivate}},
+		{scope: scope("all_group_chats"), audiences: []CommandScope{CommandScopeAll, CommandScopeGroups}},
+		{scope: scope("all_chat_administrators"), audiences: []CommandScope{CommandScopeAll, CommandScopeGroups, CommandScopeChatAdmins}},
+	}
+
+	// chat private di owner e admin
+	private := make(map[int64][]CommandScope)
+	for _, u := range bot.listUsers() {
+		if u.PrivateChatID == 0 {
+			continue
+		}
+
+		switch u.Group {
+		case groupOwner:
+			private[u.PrivateChatID] = []CommandScope{CommandScopeAll, CommandScopePrivate, CommandScopeBotAdmins, CommandScopeOwner}
+		case groupAdmin:
+			private[u.PrivateChatID] = []CommandScope{CommandScopeAll, CommandScopePrivate, CommandScopeBotAdmins}
+		}
+	}
+
+	// chat con processori disattivati
+	var groups []int64
+	for chatID := range bot.processorsConfig().DisabledProcessorsByChat {
+		if chatID > 0 {
+			if _, ok := private[chatID]; !ok {
+				private[chatID] = []CommandScope{CommandScopeAll, CommandScopePrivate}
+			}
+		} else {
+			groups = append(groups, chatID)
+		}
+	}
+
+	var chats []int64
+	for chatID := range private {
+		chats = append(chats, chatID)
+	}
+	sort.Slice(chats, func(i, j int) bool { return chats[i] < chats[j] })
+	sort.Slice(groups, func(i, j int) bool { return groups[i] < groups[j] })
+
+	for _, chatID := range chats {
+		menus = append(menus, commandsMenu{scope: chatScope("chat", chatID), audiences: private[chatID], chatID: chatID})
+	}
+	for _, chatID := range groups {
+		menus = append(menus,
+			commandsMenu{scope: chatScope("chat", chatID),
+				audiences: []CommandScope{CommandScopeAll, CommandScopeGroups}, chatID: chatID},
+			commandsMenu{scope: chatScope("chat_administrators", chatID),
+				audiences: []CommandScope{CommandScopeAll, CommandScopeGroups, CommandScopeChatAdmins}, chatID: chatID})
+	}
+
+	return menus
+}
+
+// comando dichiarato da un processore
+type processorCommand struct {
+	CommandInfo
+	processor string
+}
+
+// comandi di tutti i processori, in ordine di dispatch
+func (bot *Bot) commandsInfo() []processorCommand {
+	var commands []processorCommand
+
+	for _, e := range bot.processors {
+		var infos []CommandInfo
+		if describer, ok := e.processor.(CommandsDescriber); ok {
+			infos = describer.Commands()
+		} else {
+			infos = parseHelpCommands(e.processor.Help())
+		}
+
+		for _, info := range infos {
+			if !validMenuCommand.MatchString(info.Command) {
+				bot.Logger().Debug("Invalid menu command", "processor", e.name, "command", info.Command)
+				continue
+			}
+
+			commands = append(commands, processorCommand{CommandInfo: info, processor: e.name})
+		}
+	}
+
+	return commands
+}
+
+// ricava i comandi dalle righe di Help() nella forma "/comando parametri  descrizione"
+func parseHelpCommands(help string) []CommandInfo {
+	var infos []CommandInfo
+
+	for _, line := range strings.Split(help, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "/") {
+			continue
+		}
+
+		fields := strings.Fields(line[1:])
+		if len(fields) == 0 {
+			continue
+		}
+
+		var description string
+		if i := strings.Index(line, "  "); i >= 0 {
+			description = strings.TrimSpace(line[i:])
+		}
+
+		infos = append(infos, CommandInfo{
+			Command:     strings.ToLower(fields[0]),
+			Description: description,
+		})
+	}
+
+	return infos
+}
+
+// lingue per cui impostare il menu; "" è quella di default
+func commandsLanguages(commands []processorCommand) []string {
+	seen := make(map[string]bool)
+	langs := []string{""}
+
+	for _, c := range commands {
+		for lang := range c.Descriptions {
+			if lang != "" && !seen[lang] {
+				seen[lang] = true
+				langs = append(langs, lang)
+			}
+		}
+	}
+	sort.Strings(langs[1:])
+
+	return langs
+}
+
+// comandi visibili ai destinatari, senza quelli dei processori disattivati
+func menuCommands(commands []processorCommand, audiences []CommandScope, disabled processorSet, lang string) []menuCommand {
+	visible := make(map[CommandScope]bool)
+	for _, a := range audiences {
+		visible[a] = true
+	}
+
+	var menu []menuCommand
+	added := make(map[string]bool)
+
+	for _, c := range commands {
+		if !visible[c.Scope] || disabled.has(c.processor) || added[c.Command] {
+			continue
+		}
+		if len(menu) == maxMenuCommands {
+			break
+		}
+
+		description := c.Description
+		if translated, ok := c.Descriptions[lang]; ok && translated != "" {
+			description = translated
+		}
+		if description == "" {
+			description = c.Command
+		}
+		if utf8.RuneCountInString(description) > maxMenuDescriptionLength {
+			description = string([]rune(description)[:maxMenuDescriptionLength-1]) + "…"
+		}
+
+		added[c.Command] = true
+		menu = append(menu, menuCommand{Command: c.Command, Description: description})
+	}
+
+	return menu
+}
+
+// Commands - comandi del bot per il menu di Telegram (/owner non viene mostrato)
+func (bot *Bot) Commands() []CommandInfo {
+	commands := []CommandInfo{
+		{Command: "help"},
+		{Command: commandPing},
+		{Command: commandLanguage},
+		{Command: commandSilence},
+		{Command: commandPurge, Scope: CommandScopeBotAdmins},
+		{Command: commandUser, Scope: CommandScopeBotAdmins},
+		{Command: commandConfig, Scope: CommandScopeBotAdmins},
+		{Command: commandProcessor, Scope: CommandScopeBotAdmins},
+		{Command: commandReload, Scope: CommandScopeOwner},
+		{Command: commandStatus, Scope: CommandScopeOwner},
+	}
+
+	for i := range commands {
+		key := "bot.menu." + commands[i].Command
+		commands[i].Description = bot.T(bot.catalog.DefaultLanguage(), key)
+		commands[i].Descriptions = bot.translations(key)
+	}
+
+	return commands
+}
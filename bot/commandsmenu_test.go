@@ -0,0 +1,133 @@
+package bot
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParseHelpCommands(t *testing.T) {
+	tests := []struct {
+		help string
+		want []CommandInfo
+	}{
+		{"", nil},
+		{"no commands\n/\n", nil},
+		{
+			"/Hello  Print hello world\n",
+			[]CommandInfo{{Command: "hello", Description: "Print hello world"}},
+		},
+		{
+			"  /todo add|del [text]  Manage the list  \n/ping\nother line\n",
+			[]CommandInfo{{Command: "todo", Description: "Manage the list"}, {Command: "ping"}},
+		},
+	}
+
+	for i, test := range tests {
+		if got := parseHelpCommands(test.help); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%d: parseHelpCommands() = %v, want %v", i, got, test.want)
+		}
+	}
+}
+
+func TestCommandsLanguages(t *testing.T) {
+	tests := []struct {
+		commands []processorCommand
+		want     []string
+	}{
+		{nil, []string{""}},
+		{
+			[]processorCommand{
+				{CommandInfo: CommandInfo{Command: "a", Descriptions: map[string]string{"it": "x", "de": "y"}}},
+				{CommandInfo: CommandInfo{Command: "b", Descriptions: map[string]string{"it": "z", "": "w"}}},
+			},
+			[]string{"", "de", "it"},
+		},
+	}
+
+	for i, test := range tests {
+		if got := commandsLanguages(test.commands); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%d: commandsLanguages() = %v, want %v", i, got, test.want)
+		}
+	}
+}
+
+func TestMenuCommands(t *testing.T) {
+	command := func(name string, processor string, scope CommandScope, description string, translations map[string]string) processorCommand {
+		return processorCommand{
+			CommandInfo: CommandInfo{Command: name, Description: description, Descriptions: translations, Scope: scope},
+			processor:   processor,
+		}
+	}
+
+	commands := []processorCommand{
+		command("help", "Bot", CommandScopeAll, "Help", map[string]string{"it": "Aiuto"}),
+		command("user", "Bot", CommandScopeBotAdmins, "Users", nil),
+		command("todo", "Todo", CommandScopeAll, "", map[string]string{"it": ""}),
+		// duplicato di un processore successivo nell'ordine di dispatch
+		command("help", "Todo", CommandScopeAll, "Other help", nil),
+	}
+
+	tests := []struct {
+		audiences []CommandScope
+		disabled  processorSet
+		lang      string
+		want      []menuCommand
+	}{
+		{
+			[]CommandScope{CommandScopeAll}, nil, "",
+			[]menuCommand{{"help", "Help"}, {"todo", "todo"}},
+		},
+		// lingua tradotta, altrimenti la descrizione di default
+		{
+			[]CommandScope{CommandScopeAll, CommandScopeBotAdmins}, nil, "it",
+			[]menuCommand{{"help", "Aiuto"}, {"user", "Users"}, {"todo", "todo"}},
+		},
+		{
+			[]CommandScope{CommandScopeAll}, nil, "de",
+			[]menuCommand{{"help", "Help"}, {"todo", "todo"}},
+		},
+		// processori disattivati
+		{
+			[]CommandScope{CommandScopeAll}, processorSet{"todo": true}, "",
+			[]menuCommand{{"help", "Help"}},
+		},
+		{
+			[]CommandScope{CommandScopeOwner}, nil, "", nil,
+		},
+	}
+
+	for i, test := range tests {
+		got := menuCommands(commands, test.audiences, test.disabled, test.lang)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%d: menuCommands() = %v, want %v", i, got, test.want)
+		}
+	}
+}
+
+func TestMenuCommandsLimits(t *testing.T) {
+	var commands []processorCommand
+	for i := 0; i < maxMenuCommands+10; i++ {
+		commands = append(commands, processorCommand{
+			CommandInfo: CommandInfo{Command: "c" + strconv.Itoa(i), Description: strings.Repeat("è", maxMenuDescriptionLength+1)},
+		})
+	}
+	commands[0].Description = strings.Repeat("è", maxMenuDescriptionLength)
+
+	menu := menuCommands(commands, []CommandScope{CommandScopeAll}, nil, "")
+	if len(menu) != maxMenuCommands {
+		t.Fatal("Unexpected number of commands:", len(menu))
+	}
+
+	// troncamento sui caratteri, non sui byte
+	if menu[0].Description != commands[0].Description {
+		t.Error("Description truncated at the limit:", menu[0].Description)
+	}
+	description := menu[1].Description
+	if !utf8.ValidString(description) || utf8.RuneCountInString(description) != maxMenuDescriptionLength ||
+		!strings.HasSuffix(description, "è…") {
+		t.Error("Unexpected truncated description:", description)
+	}
+}
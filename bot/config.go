@@ -1,12 +1,20 @@
 package bot
 
 import (
+	"flag"
+	"strings"
 	"time"
+
+	"github.com/marcozaccari/AssistantBot/logger"
+	"github.com/marcozaccari/AssistantBot/settings"
 )
 
+// DefaultConfigContent (defaultconfig.go) viene generato da settings.default.json
+//go:generate go run ../internal/gendefaultconfig ../settings.default.json defaultconfig.go
+
 type configData struct {
-	SecureToken       string
-	RecoverOldUpdates bool // al riavvio processa le update ancora appese dall'ultimo shutdown
+	SecureToken       string `secret:"true"`
+	RecoverOldUpdates bool   // al riavvio processa le update ancora appese dall'ultimo shutdown
 
 	CommandWord string // se di un solo carattere lavora come "/comando"
 
@@ -14,12 +22,50 @@ type configData struct {
 
 	SilenceTimeoutMins int
 
-	OwnerID int
-	Users   []user
+	DefaultLanguage string // lingua delle risposte se non scelta dall'utente e non ricavabile da Telegram
+
+	DisabledProcessors       []string           // processori disattivati in tutte le chat
+	DisabledProcessorsByChat map[int64][]string // processori disattivati nelle singole chat
+
+	ManualCommandsMenu bool // non sincronizza il menu dei comandi di Telegram (es. se gestito con BotFather)
+
+	HTTPListen string // indirizzo del server HTTP locale per /metrics, /healthz e /status (es. "127.0.0.1:9090"); vuoto = disattivato
 }
 
 const saveAfter = 5 * time.Second
 
+// prefisso delle variabili d'ambiente che sovrascrivono la configurazione,
+// es. ASSISTANTBOT_BOT_SECURETOKEN, ASSISTANTBOT_MYSCOPE_FOO
+const configEnvPrefix = "ASSISTANTBOT"
+
+// durata di default del comando /silence
+const defaultSilenceTimeoutMins = 30
+
+func (config *configData) SetDefaults() {
+	if config.SilenceTimeoutMins == 0 {
+		config.SilenceTimeoutMins = defaultSilenceTimeoutMins
+	}
+	if config.DefaultLanguage == "" {
+		config.DefaultLanguage = defaultLanguage
+	}
+}
+
+func (config *configData) Validate() error {
+	var errs settings.Errors
+
+	if config.SecureToken == "" {
+		errs = append(errs, settings.NewFieldError("SecureToken", "required"))
+	}
+	if config.SilenceTimeoutMins < 0 {
+		errs = append(errs, settings.NewFieldError("SilenceTimeoutMins", "must be >= 0"))
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
 // SaveConfig - può essere invocata spesso dato che utilizza l'antibounce
 func (bot *Bot) SaveConfig() {
 	bot.configCtrl.SaveSettingsDebounce(saveAfter)
@@ -30,19 +76,95 @@ func (bot *Bot) SaveConfigNow() error {
 	return bot.configCtrl.SaveSettings()
 }
 
-// LoadConfig - carica le impostazioni.
+// LoadConfig - carica le impostazioni e, al primo caricamento, inizializza
+// i processori che implementano Initializer.
 // Se non viene invocata esternamente ci pensa comunque bot.Do()
 func (bot *Bot) LoadConfig() error {
+	bot.configCtrl.Strict = bot.StrictConfig
+	bot.configCtrl.SearchPaths = bot.ConfigSearchPaths
+	bot.configCtrl.AppName = bot.ConfigAppName
+	bot.configCtrl.DefaultContent = bot.DefaultConfig
+	bot.configCtrl.Logger = logger.With(bot.Logger(), "component", "settings")
+
 	err := bot.configCtrl.LoadSettings()
 	if err != nil {
 		return err
 	}
 
+	bot.catalog.SetDefaultLanguage(bot.getConfig().DefaultLanguage)
+
+	if bot.configLoaded {
+		return nil
+	}
 	bot.configLoaded = true
 
-	return nil
+	return bot.initProcessors()
 }
 
 func (bot *Bot) RegisterConfig(scope string, configData interface{}) {
 	bot.configs[scope] = configData
 }
+
+// RegisterConfigMigration - registra la migrazione della configurazione dello scope
+// dalla versione dello schema from alla from+1; va invocata prima di LoadConfig.
+// I dati vengono passati come JSON grezzo; il file viene salvato (con una copia
+// dell'originale) subito dopo la migrazione.
+func (bot *Bot) RegisterConfigMigration(scope string, from int, migrate settings.MigrationFunc) {
+	bot.configCtrl.RegisterMigration(strings.Title(scope), from, migrate)
+}
+
+// ConfigOverrideFlag - flag.Value per sovrascrivere la configurazione da riga di comando
+// ("scope.Campo=valore"); i valori sovrascritti non vengono salvati nel file.
+// Va registrato e parsato prima di LoadConfig/Do.
+func (bot *Bot) ConfigOverrideFlag() flag.Value {
+	return bot.configCtrl.OverrideFlag()
+}
+
+// ReloadConfig - ricarica la configurazione dal file; viene applicata soltanto
+// se valida. Ritorna gli scope modificati.
+func (bot *Bot) ReloadConfig() ([]string, error) {
+	return bot.configCtrl.Reload()
+}
+
+// notifica ai processori la modifica della rispettiva configurazione
+func (bot *Bot) onConfigChanged(scope string, old interface{}, new interface{}) {
+	if scope == botProcessorName {
+		bot.catalog.SetDefaultLanguage(bot.getConfig().DefaultLanguage)
+
+		// possono essere cambiati i processori disattivati
+		bot.refreshCommands()
+	}
+
+	for _, e := range bot.processors {
+		if e.name != scope {
+			continue
+		}
+
+		if observer, ok := e.processor.(ConfigObserver); ok {
+			observer.OnConfigChanged(old, new)
+		}
+	}
+}
+
+// UpdateConfig - modifica in modo sicuro la configurazione (del bot o di un processore)
+// e ne pianifica il salvataggio. Tutte le modifiche alle strutture registrate con
+// RegisterConfig devono avvenire all'interno di fn; fn non deve invocare
+// UpdateConfig, ViewConfig o SaveConfigNow.
+func (bot *Bot) UpdateConfig(fn func()) {
+	bot.configCtrl.Update(fn)
+	bot.SaveConfig()
+}
+
+// ViewConfig - legge in modo sicuro la configurazione (del bot o di un processore)
+func (bot *Bot) ViewConfig(fn func()) {
+	bot.configCtrl.View(fn)
+}
+
+// ritorna una copia della configurazione del bot
+func (bot *Bot) getConfig() (config configData) {
+	bot.configCtrl.View(func() {
+		config = bot.config
+	})
+
+	return
+}
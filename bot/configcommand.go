@@ -0,0 +1,144 @@
+package bot
+
+import (
+	"html"
+	"strings"
+
+	"github.com/marcozaccari/AssistantBot/settings"
+)
+
+func (bot *Bot) processConfigCommand(handler MessageHandler, params []string) {
+	if handler.Group != groupOwner && handler.Group != groupAdmin {
+		bot.logNoPermission(handler, commandConfig)
+		return
+	}
+
+	help := bot.T(handler.Language, "bot.config.help")
+
+	if len(params) == 0 {
+		opt := bot.NewMessageResponseOpt()
+		bot.SendMessageResponse(handler, help, opt)
+		return
+	}
+
+	switch params[0] {
+	case "show":
+		if handler.Group != groupOwner {
+			bot.logNoPermission(handler, commandConfig)
+			return
+		}
+
+		var scope string
+		if len(params) > 1 {
+			scope = params[1]
+		}
+
+		bot.showConfig(handler, scope)
+
+	case "get":
+		if len(params) != 2 {
+			opt := bot.NewMessageResponseOpt()
+			bot.SendMessageResponse(handler, help, opt)
+			return
+		}
+
+		bot.getConfigValue(handler, params[1])
+
+	case "set":
+		if len(params) < 3 {
+			opt := bot.NewMessageResponseOpt()
+			bot.SendMessageResponse(handler, help, opt)
+			return
+		}
+
+		// il valore può contenere spazi
+		bot.setConfigValue(handler, params[1], strings.Join(params[2:], " "))
+
+	default:
+		opt := bot.NewMessageResponseOpt()
+		bot.SendMessageResponse(handler, bot.T(handler.Language, "bot.unknownSubcommand", html.EscapeString(params[0])), opt)
+	}
+}
+
+// invia in privato la configurazione effettiva, con i campi riservati mascherati
+func (bot *Bot) showConfig(handler MessageHandler, scope string) {
+	var text string
+
+	dump, err := bot.configCtrl.Dump(strings.Title(scope))
+	if err != nil {
+		text = bot.T(handler.Language, "bot.config.cannotShow", html.EscapeString(err.Error()))
+	} else {
+		if scope != "" {
+			text = "<b>" + html.EscapeString(strings.Title(scope)) + "</b>\n"
+		}
+		text += "<pre>" + html.EscapeString(string(dump)) + "</pre>"
+	}
+
+	opt := bot.NewMessageResponseOpt()
+	bot.SendMessageResponseToPrivate(handler, text, opt)
+}
+
+// separa "scope.Field"
+func parseConfigPath(path string) (scope string, field string, ok bool) {
+	i := strings.Index(path, ".")
+	if i <= 0 || i == len(path)-1 {
+		return "", "", false
+	}
+
+	return path[:i], path[i+1:], true
+}
+
+func (bot *Bot) getConfigValue(handler MessageHandler, path string) {
+	var text string
+
+	scope, field, ok := parseConfigPath(path)
+	if !ok {
+		text = bot.T(handler.Language, "bot.config.expectedPath")
+	} else {
+		key, value, err := bot.configCtrl.GetValue(scope, field)
+		if err != nil {
+			text = "<code>" + html.EscapeString(err.Error()) + "</code>"
+		} else {
+			text = html.EscapeString(strings.Title(scope)+"."+key) + " = <code>" + html.EscapeString(string(value)) + "</code>"
+		}
+	}
+
+	opt := bot.NewMessageResponseOpt()
+	bot.SendMessageResponse(handler, text, opt)
+}
+
+// applica subito il nuovo valore (notificando il processore) e lo salva nel file
+func (bot *Bot) setConfigValue(handler MessageHandler, path string, value string) {
+	var text string
+
+	scope, field, ok := parseConfigPath(path)
+	if !ok {
+		text = bot.T(handler.Language, "bot.config.expectedPath")
+	} else {
+		key, err := bot.configCtrl.SetValue(scope, field, value)
+		switch {
+		case err == settings.ErrSecretField:
+			text = bot.T(handler.Language, "bot.config.secret")
+
+		case err != nil:
+			text = bot.T(handler.Language, "bot.config.cannotSet", html.EscapeString(err.Error()))
+
+		default:
+			scope = strings.Title(scope)
+			_, newValue, _ := bot.configCtrl.GetValue(scope, key)
+			text = html.EscapeString(scope+"."+key) + " = <code>" + html.EscapeString(string(newValue)) + "</code>"
+
+			if bot.configCtrl.IsOverridden(scope, key) {
+				// al salvataggio viene mantenuto il valore presente nel file
+				text += "\n" + bot.T(handler.Language, "bot.config.overridden")
+			}
+
+			bot.SaveConfig()
+
+			bot.Logger().Info("Setting changed", append(handler.logArgs(), "scope", scope, "field", key)...)
+		}
+	}
+
+	opt := bot.NewMessageResponseOpt()
+	bot.SendMessageResponse(handler, text, opt)
+}
@@ -0,0 +1,18 @@
+package bot
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestDefaultConfigContent(t *testing.T) {
+	content, err := ioutil.ReadFile("../settings.default.json")
+	if err != nil {
+		t.Fatal("Error reading default config:", err)
+	}
+
+	if !bytes.Equal(content, DefaultConfigContent) {
+		t.Error("DefaultConfigContent differs from settings.default.json: run go generate ./bot")
+	}
+}
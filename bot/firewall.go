@@ -3,8 +3,6 @@ package bot
 // Funzioni di filtraggio messaggi in input
 
 import (
-	"log"
-
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 )
 
@@ -26,6 +24,7 @@ func (bot *Bot) allowedUpdate(update *tgbotapi.Update) (allowed bool, canProcess
 
 	if message.From.IsBot {
 		// i messaggi dei bot vengono scartati
+		bot.metrics.firewallDrops.Inc("bot")
 		return false, false
 	}
 
@@ -45,15 +44,14 @@ func (bot *Bot) allowedUpdate(update *tgbotapi.Update) (allowed bool, canProcess
 		return
 	}
 
-	if bot.config.ProcessGroupMessages && !isPrivateChat {
+	if bot.getConfig().ProcessGroupMessages && !isPrivateChat {
 		allowed = true
 		canProcessCommands = false
 		return
 	}
 
-	if bot.Debug {
-		log.Println("(firewall) From ID", message.From.ID, "not in allowed IDs")
-	}
+	bot.metrics.firewallDrops.Inc("unauthorized")
+	bot.Logger().Debug("Update dropped by firewall", "chat_id", message.Chat.ID, "user_id", message.From.ID)
 
 	return
 }
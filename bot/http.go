@@ -0,0 +1,33 @@
+package bot
+
+import (
+	"net"
+	"net/http"
+)
+
+// avvia il server HTTP locale (metriche e stato) sull'indirizzo configurato in HTTPListen
+func (bot *Bot) startHTTP(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", bot.metricsRegistry.Handler())
+	mux.HandleFunc("/healthz", bot.handleHealthz)
+	mux.HandleFunc("/status", bot.handleStatus)
+
+	// l'indirizzo viene aperto subito, in modo da riportare gli errori a Do
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	bot.httpServer = &http.Server{Handler: mux}
+
+	go func() {
+		err := bot.httpServer.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			bot.Logger().Error("HTTP server stopped", "err", err)
+		}
+	}()
+
+	bot.Logger().Info("HTTP server listening", "addr", listener.Addr().String())
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package bot
+
+import (
+	"html"
+	"strings"
+
+	"github.com/marcozaccari/AssistantBot/i18n"
+)
+
+// lingua di default delle risposte, se non configurata (DefaultLanguage)
+const defaultLanguage = "en"
+
+// lingua preferita di un utente o di una chat, nello stato di runtime (vedi /language)
+const stateKeyLanguage = "language"
+
+// LocalizedHelper - interfaccia opzionale dei processori che traducono il proprio
+// help; se implementata /help la preferisce a Help()
+type LocalizedHelper interface {
+	LocalizedHelp(lang string) string
+}
+
+func (bot *Bot) initTranslations() {
+	bot.catalog = i18n.NewCatalog(defaultLanguage)
+
+	for lang, messages := range builtinTranslations {
+		bot.catalog.Add(lang, messages)
+	}
+}
+
+// RegisterTranslations - aggiunge al catalogo i messaggi di una lingua (es. "it").
+// Le chiavi dei processori vanno prefissate con il proprio scope (es. "myscope.hello")
+// per non sovrascrivere quelle del bot ("bot.*"); i messaggi possono contenere
+// i verbi di fmt.Sprintf.
+func (bot *Bot) RegisterTranslations(lang string, messages map[string]string) {
+	bot.catalog.Add(lang, messages)
+}
+
+// T - messaggio tradotto nella lingua (es. handler.Language); se assente viene
+// usata la lingua di default e, in mancanza, la chiave stessa
+func (bot *Bot) T(lang string, key string, args ...interface{}) string {
+	return bot.catalog.Translate(lang, key, args...)
+}
+
+// traduce nella lingua; utile per i testi composti da più messaggi
+type translator func(key string, args ...interface{}) string
+
+func (bot *Bot) translator(lang string) translator {
+	return func(key string, args ...interface{}) string {
+		return bot.T(lang, key, args...)
+	}
+}
+
+// tutte le traduzioni della chiave, per lingua (es. per CommandInfo.Descriptions)
+func (bot *Bot) translations(key string) map[string]string {
+	translations := make(map[string]string)
+
+	for _, lang := range bot.catalog.Languages() {
+		if message, ok := bot.catalog.Lookup(lang, key); ok {
+			translations[lang] = message
+		}
+	}
+
+	return translations
+}
+
+// lingua delle risposte: quella scelta per la chat, quella scelta dall'utente,
+// quella del client Telegram (language_code) se disponibile, altrimenti quella di default
+func (bot *Bot) resolveLanguage(userID int, chatID int64, languageCode string) string {
+	state, err := bot.stateStorage()
+	if err == nil {
+		var lang string
+
+		if ok, _ := state.Chat(chatID).Get(stateKeyLanguage, &lang); ok && lang != "" {
+			return lang
+		}
+		if ok, _ := state.User(userID).Get(stateKeyLanguage, &lang); ok && lang != "" {
+			return lang
+		}
+	}
+
+	if lang, ok := bot.catalog.Supported(languageCode); ok {
+		return lang
+	}
+
+	return bot.catalog.DefaultLanguage()
+}
+
+// /language [codice|default] [chat]
+func (bot *Bot) processLanguageCommand(handler MessageHandler, params []string) {
+	available := strings.Join(bot.catalog.Languages(), ", ")
+	opt := bot.NewMessageResponseOpt()
+
+	if len(params) == 0 {
+		text := bot.T(handler.Language, "bot.language.current", handler.Language, available)
+		bot.SendMessageResponse(handler, text, opt)
+		return
+	}
+
+	forChat := len(params) > 1 && params[1] == "chat" && !handler.IsPrivate
+	if forChat && handler.Group != groupOwner && handler.Group != groupAdmin {
+		bot.logNoPermission(handler, commandLanguage)
+		return
+	}
+
+	var lang string
+	if params[0] != "default" {
+		var ok bool
+		lang, ok = bot.catalog.Supported(params[0])
+		if !ok {
+			text := bot.T(handler.Language, "bot.language.unknown", html.EscapeString(params[0]), available)
+			bot.SendMessageResponse(handler, text, opt)
+			return
+		}
+	}
+
+	state, err := bot.stateStorage()
+	if err != nil {
+		bot.Logger().Error("Cannot open state store", "err", err)
+		return
+	}
+
+	key := state.User(handler.UserID)
+	if forChat {
+		key = state.Chat(handler.ChatID)
+	}
+
+	if lang == "" {
+		err = key.Delete(stateKeyLanguage)
+	} else {
+		err = key.Set(stateKeyLanguage, lang)
+	}
+	if err != nil {
+		bot.Logger().Error("Cannot store language", append(handler.logArgs(), "err", err)...)
+		return
+	}
+
+	// la risposta è già nella nuova lingua
+	handler.Language = bot.resolveLanguage(handler.UserID, handler.ChatID, handler.LanguageCode)
+
+	var text string
+	switch {
+	case lang == "" && forChat:
+		text = bot.T(handler.Language, "bot.language.resetChat")
+	case lang == "":
+		text = bot.T(handler.Language, "bot.language.reset")
+	case forChat:
+		text = bot.T(handler.Language, "bot.language.setChat", lang)
+	default:
+		text = bot.T(handler.Language, "bot.language.set", lang)
+	}
+
+	bot.SendMessageResponse(handler, text, opt)
+}
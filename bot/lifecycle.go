@@ -0,0 +1,137 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Interfacce opzionali dei processori, individuate tramite type assertion
+// in modo che i processori esistenti non debbano implementarle.
+
+// Initializer - invocato una sola volta dopo il caricamento della configurazione
+// (LoadConfig), con il bot a cui il processore è registrato; utilizzabile ad
+// esempio per aprire risorse che dipendono dalla configurazione o dallo storage.
+type Initializer interface {
+	Init(bot *Bot) error
+}
+
+// Starter - invocato da Do, a stack inizializzato, prima di ricevere le update
+type Starter interface {
+	Start() error
+}
+
+// Stopper - invocato all'uscita di Do (anche per errore), in ordine inverso,
+// per i processori il cui Start è andato a buon fine o che non implementano Starter
+type Stopper interface {
+	Stop() error
+}
+
+// tempo massimo concesso al server HTTP per chiudere le richieste in corso
+const httpShutdownTimeout = 5 * time.Second
+
+// invoca Init dei processori; il bot non viene considerato
+func (bot *Bot) initProcessors() error {
+	for _, e := range bot.processors {
+		if e.processor == Processor(bot) {
+			continue
+		}
+
+		if i, ok := e.processor.(Initializer); ok {
+			err := i.Init(bot)
+			if err != nil {
+				return errors.New("(init " + e.name + ") " + err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// invoca Start dei processori; ritorna quelli da arrestare all'uscita
+func (bot *Bot) startProcessors() ([]*processorEntry, error) {
+	var started []*processorEntry
+
+	for _, e := range bot.processors {
+		if e.processor == Processor(bot) {
+			continue
+		}
+
+		if s, ok := e.processor.(Starter); ok {
+			err := s.Start()
+			if err != nil {
+				return started, errors.New("(start " + e.name + ") " + err.Error())
+			}
+		}
+
+		started = append(started, e)
+	}
+
+	return started, nil
+}
+
+// invoca Stop dei processori in ordine inverso
+func (bot *Bot) stopProcessors(started []*processorEntry) {
+	for i := len(started) - 1; i >= 0; i-- {
+		e := started[i]
+
+		if s, ok := e.processor.(Stopper); ok {
+			err := s.Stop()
+			if err != nil {
+				bot.Logger().Error("Cannot stop processor", "processor", e.name, "err", err)
+			}
+		}
+	}
+}
+
+// Stop - interrompe la ricezione delle update: Do arresta i processori e ritorna nil.
+// Può essere invocata da qualsiasi goroutine (es. alla ricezione di SIGTERM).
+func (bot *Bot) Stop() {
+	bot.stopOnce.Do(func() {
+		close(bot.stop)
+	})
+}
+
+// rilascia le risorse all'uscita di Do
+func (bot *Bot) shutdown(started []*processorEntry) {
+	bot.Logger().Info("Shutting down")
+
+	bot.statusLock.Lock()
+	bot.status.running = false
+	bot.statusLock.Unlock()
+
+	if bot.Tgbot != nil {
+		bot.Tgbot.StopReceivingUpdates()
+	}
+
+	bot.stopProcessors(started)
+
+	bot.configCtrl.StopWatch()
+
+	// i salvataggi differiti in attesa vengono eseguiti subito
+	if bot.configCtrl.SaveStatus().Pending {
+		err := bot.SaveConfigNow()
+		if err != nil {
+			bot.Logger().Error("Cannot save settings", "err", err)
+		}
+	}
+
+	if bot.httpServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		bot.httpServer.Shutdown(ctx)
+		cancel()
+	}
+
+	bot.closeStore()
+}
+
+// chiude lo store, se aperto, rilasciandone il lock sul file
+func (bot *Bot) closeStore() {
+	bot.storeLock.Lock()
+	defer bot.storeLock.Unlock()
+
+	if bot.store != nil {
+		bot.store.Close()
+		bot.store = nil
+	}
+}
@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/marcozaccari/AssistantBot/storage"
+)
+
+// processore che registra le chiamate di Init, Start e Stop in events
+type lifecycleProcessor struct {
+	StubProcessor
+	name     string
+	startErr error
+	events   *[]string
+}
+
+func (p *lifecycleProcessor) Init(bot *Bot) error {
+	*p.events = append(*p.events, p.name+":init")
+	return nil
+}
+
+func (p *lifecycleProcessor) Start() error {
+	*p.events = append(*p.events, p.name+":start")
+	return p.startErr
+}
+
+func (p *lifecycleProcessor) Stop() error {
+	*p.events = append(*p.events, p.name+":stop")
+	return nil
+}
+
+// API di Telegram che rifiutano il token
+type unauthorizedTelegram struct{}
+
+func (unauthorizedTelegram) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusUnauthorized,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(`{"ok": false, "error_code": 401, "description": "Unauthorized"}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestLifecycle(t *testing.T) {
+	var events []string
+
+	bot := newTestBot(t)
+	for _, name := range []string{"A", "B", "C"} {
+		bot.RegisterProcessor(name, &lifecycleProcessor{name: name, events: &events}, nil)
+	}
+
+	// Init viene invocato una sola volta, anche se Do segue LoadConfig
+	if err := bot.LoadConfig(); err != nil {
+		t.Fatal("Error loading config:", err)
+	}
+	if err := bot.LoadConfig(); err != nil {
+		t.Fatal("Error reloading config:", err)
+	}
+
+	run := runTestBot(t, bot)
+	run.waitRunning(t)
+
+	// Stop termina Do senza errori
+	if err := run.stop(); err != nil {
+		t.Fatal("Do error:", err)
+	}
+
+	want := []string{
+		"A:init", "B:init", "C:init",
+		"A:start", "B:start", "C:start",
+		"C:stop", "B:stop", "A:stop",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+	if bot.store != nil {
+		t.Error("Store not closed")
+	}
+}
+
+func TestLifecycleStartError(t *testing.T) {
+	var events []string
+
+	bot := newTestBot(t)
+	bot.RegisterProcessor("A", &lifecycleProcessor{name: "A", events: &events}, nil)
+	bot.RegisterProcessor("B", &lifecycleProcessor{name: "B", events: &events, startErr: errors.New("failed")}, nil)
+	bot.RegisterProcessor("C", &lifecycleProcessor{name: "C", events: &events}, nil)
+	bot.apiClient = &http.Client{Transport: fakeTelegram{}}
+
+	err := bot.Do()
+	if err == nil || !strings.Contains(err.Error(), "(start B)") {
+		t.Fatal("Unexpected Do error:", err)
+	}
+
+	// Stop soltanto per i processori avviati: non per B (Start fallito) nè per C
+	want := []string{
+		"A:init", "B:init", "C:init",
+		"A:start", "B:start",
+		"A:stop",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+}
+
+func TestDoStackError(t *testing.T) {
+	bot := newTestBot(t)
+	bot.StateFilename = "state.db"
+	bot.apiClient = &http.Client{Transport: unauthorizedTelegram{}}
+
+	if err := bot.Do(); err == nil || !strings.HasPrefix(err.Error(), "(stack)") {
+		t.Fatal("Unexpected Do error:", err)
+	}
+
+	// il lock di bbolt viene rilasciato
+	if bot.store != nil {
+		t.Error("Store not closed")
+	}
+	store, err := storage.Open(bot.stateFilename())
+	if err != nil {
+		t.Fatal("Store still locked:", err)
+	}
+	store.Close()
+}
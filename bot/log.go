@@ -0,0 +1,68 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/marcozaccari/AssistantBot/logger"
+)
+
+// SetLogger - imposta il logger del bot e delle impostazioni (es. slog.Default()).
+// Di default i log sono scritti su stderr a partire dal livello Debug se Debug,
+// Info se Verbose, altrimenti Warn.
+func (bot *Bot) SetLogger(l logger.Logger) {
+	bot.logLock.Lock()
+	bot.log = l
+	bot.logLock.Unlock()
+
+	bot.configCtrl.Logger = logger.With(l, "component", "settings")
+}
+
+// Logger - logger del bot, utilizzabile anche dai processori
+// (es. logger.With(bot.Logger(), "processor", "Myscope"))
+func (bot *Bot) Logger() logger.Logger {
+	bot.logLock.Lock()
+	defer bot.logLock.Unlock()
+
+	if bot.log == nil {
+		level := logger.LevelWarn
+		switch {
+		case bot.Debug:
+			level = logger.LevelDebug
+		case bot.Verbose:
+			level = logger.LevelInfo
+		}
+
+		bot.log = logger.Default(level)
+	}
+
+	return bot.log
+}
+
+// logger di telegram-bot-api (tgbotapi.BotLogger): i messaggi della libreria, come
+// gli errori di rete che contengono l'URL delle API e quindi il token, vengono
+// mascherati e scritti nel logger del bot
+type stackLogger struct {
+	bot *Bot
+}
+
+func (l stackLogger) Println(v ...interface{}) {
+	l.log(strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+}
+
+func (l stackLogger) Printf(format string, v ...interface{}) {
+	l.log(strings.TrimSuffix(fmt.Sprintf(format, v...), "\n"))
+}
+
+func (l stackLogger) log(message string) {
+	l.bot.Logger().Warn("Telegram API", "component", "telegram-bot-api", "message", l.bot.redact(message))
+}
+
+// campi che identificano il messaggio nei log
+func (handler MessageHandler) logArgs() []interface{} {
+	return []interface{}{"chat_id", handler.ChatID, "user_id", handler.UserID}
+}
+
+func (bot *Bot) logNoPermission(handler MessageHandler, command string) {
+	bot.Logger().Info("No permission for command", append(handler.logArgs(), "command", command)...)
+}
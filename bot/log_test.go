@@ -0,0 +1,40 @@
+package bot
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/marcozaccari/AssistantBot/logger"
+	"github.com/marcozaccari/AssistantBot/settings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func TestStackLoggerRedactsToken(t *testing.T) {
+	const token = "123456:secret-token"
+
+	bot := newTestBot(t)
+	bot.configCtrl.SetOverride(botProcessorName, "SecureToken", token)
+	if err := bot.LoadConfig(); err != nil {
+		t.Fatal("Error loading config:", err)
+	}
+
+	var buf bytes.Buffer
+	bot.SetLogger(logger.New(&buf, logger.Options{Level: logger.LevelDebug}))
+
+	// come GetUpdatesChan in caso di errore di rete
+	var l tgbotapi.BotLogger = stackLogger{bot}
+	l.Println(&url.Error{Op: "Post", URL: "https://api.telegram.org/bot" + token + "/getUpdates", Err: errors.New("timeout")})
+	l.Printf("%s %s", "getUpdates", token)
+
+	out := buf.String()
+	if strings.Contains(out, token) || strings.Contains(out, "secret") {
+		t.Error("Token logged:", out)
+	}
+	if strings.Count(out, settings.RedactedMask) != 2 || strings.Count(out, "\n") != 2 {
+		t.Error("Unexpected log:", out)
+	}
+}
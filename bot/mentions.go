@@ -0,0 +1,288 @@
+package bot
+
+// Risoluzione degli utenti menzionati: ID numerici, username della whitelist o
+// degli utenti visti nelle chat, menzioni testuali (text_mention) dei messaggi.
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// utenti visti nelle chat, nello stato di runtime
+const stateKeySeenPrefix = "seen/"
+
+// numero massimo di utenti visti conservati; oltre vengono rimossi i meno recenti
+const maxSeenUsers = 5000
+
+// UserRef - riferimento a un utente, risolto da un argomento di comando
+// (vedi ParseUserRef e UserRefArg)
+type UserRef struct {
+	ID        int
+	Username  string // può essere vuoto
+	FirstName string
+	LastName  string
+}
+
+// Name - nome da mostrare: nome e cognome, altrimenti username, altrimenti ID
+func (ref UserRef) Name() string {
+	name := strings.TrimSpace(ref.FirstName + " " + ref.LastName)
+	if name != "" {
+		return name
+	}
+	if ref.Username != "" {
+		return "@" + ref.Username
+	}
+	return strconv.Itoa(ref.ID)
+}
+
+// Mention - utente menzionato nel messaggio
+type Mention struct {
+	User UserRef
+	Text string // testo della menzione (es. "@mario" oppure "Mario Rossi" per le text_mention)
+}
+
+// utente visto in una chat
+type seenUser struct {
+	UserRef
+	LastSeen time.Time
+}
+
+func userRef(u *tgbotapi.User) UserRef {
+	return UserRef{ID: u.ID, Username: u.UserName, FirstName: u.FirstName, LastName: u.LastName}
+}
+
+// carica gli utenti visti dallo store
+func (bot *Bot) loadSeenUsers() error {
+	state, err := bot.stateStorage()
+	if err != nil {
+		return err
+	}
+
+	keys, err := state.List(stateKeySeenPrefix)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[int]*seenUser)
+	for _, key := range keys {
+		u := seenUser{}
+		ok, err := state.Get(key, &u)
+		if err != nil {
+			return err
+		}
+		if ok {
+			seen[u.ID] = &u
+		}
+	}
+
+	bot.seenLock.Lock()
+	bot.seenUsers = seen
+	bot.seenLock.Unlock()
+
+	return nil
+}
+
+// registra gli utenti presenti nel messaggio (mittente, risposta, menzioni, nuovi membri)
+func (bot *Bot) recordSeenUsers(message *tgbotapi.Message) {
+	users := []*tgbotapi.User{message.From}
+
+	if message.ReplyToMessage != nil {
+		users = append(users, message.ReplyToMessage.From)
+	}
+	if message.NewChatMembers != nil {
+		for i := range *message.NewChatMembers {
+			users = append(users, &(*message.NewChatMembers)[i])
+		}
+	}
+	if message.Entities != nil {
+		for _, e := range *message.Entities {
+			if e.Type == "text_mention" {
+				users = append(users, e.User)
+			}
+		}
+	}
+
+	for _, u := range users {
+		if u != nil && !u.IsBot {
+			bot.recordSeenUser(userRef(u))
+		}
+	}
+}
+
+func (bot *Bot) recordSeenUser(ref UserRef) {
+	bot.seenLock.Lock()
+	defer bot.seenLock.Unlock()
+
+	if bot.seenUsers == nil {
+		bot.seenUsers = make(map[int]*seenUser)
+	}
+
+	now := time.Now()
+
+	u, ok := bot.seenUsers[ref.ID]
+	if ok && u.UserRef == ref {
+		u.LastSeen = now
+		return
+	}
+
+	bot.seenUsers[ref.ID] = &seenUser{UserRef: ref, LastSeen: now}
+
+	state, err := bot.stateStorage()
+	if err != nil {
+		return
+	}
+
+	// vengono salvati soltanto gli utenti nuovi o modificati
+	err = state.Set(stateKeySeenPrefix+strconv.Itoa(ref.ID), bot.seenUsers[ref.ID])
+	if err != nil {
+		bot.Logger().Error("Cannot store seen user", "user_id", ref.ID, "err", err)
+	}
+
+	if len(bot.seenUsers) > maxSeenUsers {
+		var oldest *seenUser
+		for _, u := range bot.seenUsers {
+			if oldest == nil || u.LastSeen.Before(oldest.LastSeen) {
+				oldest = u
+			}
+		}
+
+		delete(bot.seenUsers, oldest.ID)
+		state.Delete(stateKeySeenPrefix + strconv.Itoa(oldest.ID))
+	}
+}
+
+// utente visto nelle chat, per ID
+func (bot *Bot) seenUserByID(userID int) (UserRef, bool) {
+	bot.seenLock.Lock()
+	defer bot.seenLock.Unlock()
+
+	if u, ok := bot.seenUsers[userID]; ok {
+		return u.UserRef, true
+	}
+	return UserRef{}, false
+}
+
+// utente visto nelle chat, per username (senza distinzione di maiuscole)
+func (bot *Bot) seenUserByUsername(username string) (UserRef, bool) {
+	bot.seenLock.Lock()
+	defer bot.seenLock.Unlock()
+
+	var found *seenUser
+	for _, u := range bot.seenUsers {
+		// lo stesso username può essere passato ad un altro utente: vale il più recente
+		if strings.EqualFold(u.Username, username) && (found == nil || u.LastSeen.After(found.LastSeen)) {
+			found = u
+		}
+	}
+
+	if found == nil {
+		return UserRef{}, false
+	}
+	return found.UserRef, true
+}
+
+// testo di un'entità; offset e lunghezza sono espressi in unità UTF-16
+func entityText(text string, e tgbotapi.MessageEntity) string {
+	units := utf16.Encode([]rune(text))
+	if e.Offset < 0 || e.Length < 0 || e.Offset+e.Length > len(units) {
+		return ""
+	}
+
+	return string(utf16.Decode(units[e.Offset : e.Offset+e.Length]))
+}
+
+// utenti menzionati nel messaggio: le text_mention (utenti senza username)
+// e le @menzioni di utenti noti
+func (bot *Bot) messageMentions(message *tgbotapi.Message) []Mention {
+	if message.Entities == nil {
+		return nil
+	}
+
+	var mentions []Mention
+	for _, e := range *message.Entities {
+		text := entityText(message.Text, e)
+
+		switch e.Type {
+		case "text_mention":
+			if e.User != nil {
+				mentions = append(mentions, Mention{User: userRef(e.User), Text: text})
+			}
+
+		case "mention":
+			if ref, ok := bot.lookupUsername(strings.TrimPrefix(text, "@")); ok {
+				mentions = append(mentions, Mention{User: ref, Text: text})
+			}
+		}
+	}
+
+	return mentions
+}
+
+// username nella whitelist o tra gli utenti visti
+func (bot *Bot) lookupUsername(username string) (UserRef, bool) {
+	if u, ok := bot.getUserByUsername(username); ok {
+		ref, _ := bot.seenUserByID(u.ID)
+		ref.ID = u.ID
+		ref.Username = u.Username
+		return ref, true
+	}
+
+	return bot.seenUserByUsername(username)
+}
+
+// ParseUserRef - risolve un argomento che identifica un utente:
+// un ID numerico, la prima parola di una menzione testuale del messaggio
+// (text_mention, per gli utenti senza username), "@username" o "username"
+// di un utente della whitelist o visto nelle chat.
+// Gli username non ancora visti dal bot non sono risolvibili.
+func (bot *Bot) ParseUserRef(handler MessageHandler, s string) (UserRef, bool) {
+	if s == "" {
+		return UserRef{}, false
+	}
+
+	if userID, err := strconv.Atoi(s); err == nil {
+		if userID <= 0 {
+			return UserRef{}, false
+		}
+
+		ref, ok := bot.seenUserByID(userID)
+		if !ok {
+			ref.ID = userID
+			if u, ok := bot.getUserByID(userID); ok {
+				ref.Username = u.Username
+			}
+		}
+		return ref, true
+	}
+
+	for _, m := range handler.Mentions {
+		fields := strings.Fields(m.Text)
+		if m.Text == s || (len(fields) > 0 && fields[0] == s) {
+			return m.User, true
+		}
+	}
+
+	return bot.lookupUsername(strings.TrimPrefix(s, "@"))
+}
+
+// UserRefArg - come ParseUserRef per il parametro i; se assente viene usato
+// l'utente del messaggio a cui si è risposto
+func (bot *Bot) UserRefArg(handler MessageHandler, params []string, i int) (UserRef, bool) {
+	if i < len(params) {
+		return bot.ParseUserRef(handler, params[i])
+	}
+
+	if handler.ReplyUserID == 0 {
+		return UserRef{}, false
+	}
+
+	ref, ok := bot.seenUserByID(handler.ReplyUserID)
+	if !ok {
+		ref = UserRef{ID: handler.ReplyUserID, Username: handler.ReplyUsername}
+	}
+	return ref, true
+}
@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func TestEntityText(t *testing.T) {
+	tests := []struct {
+		text   string
+		offset int
+		length int
+		want   string
+	}{
+		{"ciao @mario", 5, 6, "@mario"},
+		// "è" occupa un'unità UTF-16 ma due byte
+		{"è @mario", 2, 6, "@mario"},
+		// le emoji fuori dal BMP occupano due unità UTF-16 (coppia surrogata)
+		{"😀 @mario", 3, 6, "@mario"},
+		{"👍🏽👍🏽 Mario Rossi!", 9, 11, "Mario Rossi"},
+		{"@mario 😀", 7, 2, "😀"},
+		// entità fuori dal testo
+		{"ciao", 2, 5, ""},
+		{"ciao", -1, 2, ""},
+	}
+
+	for i, test := range tests {
+		e := tgbotapi.MessageEntity{Type: "mention", Offset: test.offset, Length: test.length}
+		if got := entityText(test.text, e); got != test.want {
+			t.Errorf("%d: entityText() = %q, want %q", i, got, test.want)
+		}
+	}
+}
+
+func TestParseUserRef(t *testing.T) {
+	bot := newTestBot(t)
+	bot.lookupUsers = usersLookupMap{
+		1: &user{ID: 1, Username: "Owner", Group: groupOwner},
+		2: &user{ID: 2, Username: "admin", Group: groupAdmin},
+	}
+
+	now := time.Now()
+	bot.seenUsers = map[int]*seenUser{
+		1: {UserRef{ID: 1, Username: "Owner", FirstName: "Anna"}, now},
+		3: {UserRef{ID: 3, Username: "mario", FirstName: "Mario"}, now.Add(-time.Hour)},
+		// username passato ad un altro utente: vale il più recente
+		4: {UserRef{ID: 4, Username: "mario", FirstName: "Mario Bis"}, now},
+	}
+
+	handler := MessageHandler{
+		Mentions: []Mention{{User: UserRef{ID: 5, FirstName: "Luigi", LastName: "Verdi"}, Text: "Luigi Verdi"}},
+	}
+
+	tests := []struct {
+		s    string
+		want UserRef
+		ok   bool
+	}{
+		{"1", UserRef{ID: 1, Username: "Owner", FirstName: "Anna"}, true},
+		{"2", UserRef{ID: 2, Username: "admin"}, true},
+		{"99", UserRef{ID: 99}, true},
+		{"0", UserRef{}, false},
+		{"-5", UserRef{}, false},
+		{"@owner", UserRef{ID: 1, Username: "Owner", FirstName: "Anna"}, true},
+		{"admin", UserRef{ID: 2, Username: "admin"}, true},
+		{"@Mario", UserRef{ID: 4, Username: "mario", FirstName: "Mario Bis"}, true},
+		{"Luigi", UserRef{ID: 5, FirstName: "Luigi", LastName: "Verdi"}, true},
+		{"Luigi Verdi", UserRef{ID: 5, FirstName: "Luigi", LastName: "Verdi"}, true},
+		{"Verdi", UserRef{}, false},
+		{"@unknown", UserRef{}, false},
+		{"", UserRef{}, false},
+	}
+
+	for i, test := range tests {
+		got, ok := bot.ParseUserRef(handler, test.s)
+		if ok != test.ok || got != test.want {
+			t.Errorf("%d: ParseUserRef(%q) = %v, %v, want %v, %v", i, test.s, got, ok, test.want, test.ok)
+		}
+	}
+}
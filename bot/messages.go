@@ -1,8 +1,11 @@
 package bot
 
 import (
-	"log"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/marcozaccari/AssistantBot/markup"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 )
@@ -19,8 +22,14 @@ type MessageHandler struct {
 	MessageID     int // messaggio a cui appartiene il comando
 	EditMessageID int // se > 0 messaggio da editare anzichè inviarne uno nuovo
 
-	ReplyUserID   int // utente del messaggio a cui si è risposto
-	ReplyUsername string
+	ReplyUserID    int // utente del messaggio a cui si è risposto
+	ReplyUsername  string
+	ReplyMessageID int // messaggio a cui si è risposto
+
+	Mentions []Mention // utenti menzionati nel messaggio (vedi ParseUserRef)
+
+	LanguageCode string // lingua del client Telegram dell'utente (language_code)
+	Language     string // lingua delle risposte (vedi Bot.T e /language)
 }
 
 // MessageResponseOpt contiene i flag di modalità di risposta
@@ -31,6 +40,13 @@ type MessageResponseOpt struct {
 	LinksPreview         bool
 	HTMLformat           bool
 
+	// la risposta viene eliminata insieme al messaggio mittente, se questo
+	// viene eliminato tramite il bot (DeleteMessage, /purge)
+	DeleteWithSender bool
+	// se > 0 la risposta viene eliminata automaticamente dopo questo intervallo;
+	// le risposte effimere non vengono indicizzate nella lookup
+	DeleteAfter time.Duration
+
 	KeyboardInline *tgbotapi.InlineKeyboardMarkup
 	KeyboardReply  *tgbotapi.ReplyKeyboardMarkup
 }
@@ -38,16 +54,31 @@ type MessageResponseOpt struct {
 // ogni quanti messaggi inviati (x2) deve pulire la prima metà di lookup*
 const gcMaxSentMessages = 100
 
+// durata delle risposte effimere (es. /ping, avviso "pvt")
+const ephemeralDeleteAfter = time.Minute
+
+// identifica un messaggio; gli ID dei messaggi sono univoci solo all'interno della chat
+type messageKey struct {
+	ChatID    int64
+	MessageID int
+}
+
+// messaggio di risposta inviato dal bot
+type sentMessage struct {
+	messageKey
+	deleteWithSender bool
+}
+
 // lega i messaggi utente con i messaggi di risposta del bot.
 // in questo modo se l'utente modifica un suo precedente messaggio-comando,
 // anche il bot risponde modificando il suo precedente messaggio.
 type sentMessagesLookups struct {
 	locker sync.Mutex
 
-	// chiave: ID messaggio mittente; valore: ID messaggio inviato
-	lookupSenderSent map[int]int
-	// valore: ID messaggio mittente
-	lookupSent  []int
+	// chiave: messaggio mittente; valore: messaggio inviato
+	lookupSenderSent map[messageKey]sentMessage
+	// valore: messaggio mittente
+	lookupSent  []messageKey
 	sentCounter int
 }
 
@@ -60,20 +91,165 @@ func (bot *Bot) NewMessageResponseOpt() MessageResponseOpt {
 	}
 }
 
+// DeleteMessage elimina un messaggio; se si tratta di un messaggio mittente
+// la cui risposta è stata inviata con DeleteWithSender, elimina anche la risposta.
 func (bot *Bot) DeleteMessage(chatID int64, messageID int) error {
 	mc := tgbotapi.NewDeleteMessage(chatID, messageID)
 	_, err := bot.Tgbot.DeleteMessage(mc)
+
+	sent, ok := bot.lookupSentMessage(chatID, messageID)
+	if ok && sent.deleteWithSender {
+		bot.forgetSentMessage(chatID, messageID)
+
+		mc := tgbotapi.NewDeleteMessage(sent.ChatID, sent.MessageID)
+		bot.Tgbot.DeleteMessage(mc)
+	}
+
 	return err
 }
 
-// SendMessageResponse invia un messaggio di risposta all'handler
+// elimina un messaggio dopo un certo intervallo
+func (bot *Bot) deleteMessageAfter(chatID int64, messageID int, after time.Duration) {
+	time.AfterFunc(after, func() {
+		mc := tgbotapi.NewDeleteMessage(chatID, messageID)
+		bot.Tgbot.DeleteMessage(mc)
+	})
+}
+
+// ritorna la risposta inviata dal bot al messaggio mittente
+func (bot *Bot) lookupSentMessage(chatID int64, messageID int) (sent sentMessage, ok bool) {
+	bot.sentMessages.locker.Lock()
+	defer bot.sentMessages.locker.Unlock()
+
+	sent, ok = bot.sentMessages.lookupSenderSent[messageKey{chatID, messageID}]
+	return
+}
+
+// rimuove dalla lookup la risposta al messaggio mittente
+func (bot *Bot) forgetSentMessage(chatID int64, messageID int) {
+	bot.sentMessages.locker.Lock()
+	defer bot.sentMessages.locker.Unlock()
+
+	delete(bot.sentMessages.lookupSenderSent, messageKey{chatID, messageID})
+}
+
+// PurgeResponses elimina le ultime n risposte del bot presenti nella chat
+// (tra quelle indicizzate nella lookup); ritorna il numero di messaggi eliminati.
+func (bot *Bot) PurgeResponses(chatID int64, n int) int {
+	toDelete := bot.sentMessages.take(chatID, n)
+
+	deleted := 0
+	for _, key := range toDelete {
+		mc := tgbotapi.NewDeleteMessage(key.ChatID, key.MessageID)
+		if _, err := bot.Tgbot.DeleteMessage(mc); err == nil {
+			deleted++
+		}
+	}
+
+	return deleted
+}
+
+// PurgeResponse elimina la risposta del bot al messaggio mittente; ritorna false se non presente
+func (bot *Bot) PurgeResponse(chatID int64, senderMessageID int) bool {
+	sent, ok := bot.lookupSentMessage(chatID, senderMessageID)
+	if !ok {
+		return false
+	}
+
+	bot.forgetSentMessage(chatID, senderMessageID)
+
+	mc := tgbotapi.NewDeleteMessage(sent.ChatID, sent.MessageID)
+	_, err := bot.Tgbot.DeleteMessage(mc)
+	return err == nil
+}
+
+// invia (o modifica) un messaggio, registrandone l'esito
+func (bot *Bot) send(chatID int64, c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	msg, err := bot.Tgbot.Send(c)
+	if err != nil {
+		bot.metrics.sendErrors.Inc()
+		bot.Logger().Warn("Cannot send message", "chat_id", chatID, "err", bot.redactError(err))
+		return msg, err
+	}
+
+	bot.metrics.sent.Inc()
+	return msg, nil
+}
+
+// Telegram rifiuta i messaggi con formattazione non valida
+func isEntitiesError(err error) bool {
+	return strings.Contains(err.Error(), "can't parse entities")
+}
+
+// invia un messaggio formattato; se Telegram ne rifiuta la formattazione lo
+// reinvia come testo semplice (plain, se vuoto viene ricavato dal testo)
+func (bot *Bot) sendFormatted(chatID int64, c tgbotapi.Chattable, plain string) (tgbotapi.Message, error) {
+	msg, err := bot.send(chatID, c)
+	if err == nil || !isEntitiesError(err) {
+		return msg, err
+	}
+
+	switch m := c.(type) {
+	case tgbotapi.MessageConfig:
+		if plain == "" {
+			plain = markup.Strip(m.Text, markup.Mode(m.ParseMode))
+		}
+		m.Text = plain
+		m.ParseMode = ""
+		c = m
+
+	case tgbotapi.EditMessageTextConfig:
+		if plain == "" {
+			plain = markup.Strip(m.Text, markup.Mode(m.ParseMode))
+		}
+		m.Text = plain
+		m.ParseMode = ""
+		c = m
+
+	default:
+		return msg, err
+	}
+
+	bot.Logger().Info("Sending message as plain text", "chat_id", chatID)
+	return bot.send(chatID, c)
+}
+
+// NewMarkup - builder di un messaggio formattato secondo opt (HTML o MarkdownV2),
+// da inviare con SendMarkupResponse
+func (bot *Bot) NewMarkup(opt MessageResponseOpt) *markup.Builder {
+	if opt.HTMLformat {
+		return markup.New(markup.HTML)
+	}
+	return markup.New(markup.MarkdownV2)
+}
+
+// SendMarkupResponse invia un messaggio formattato di risposta all'handler;
+// se Telegram ne rifiuta la formattazione viene inviato come testo semplice
+func (bot *Bot) SendMarkupResponse(handler MessageHandler, m *markup.Builder, opt MessageResponseOpt) {
+	opt.HTMLformat = m.Mode() == markup.HTML
+	bot.sendResponse(handler, m.String(), m.Plain(), opt)
+}
+
+// SendMarkupResponseToPrivate invia un messaggio formattato di risposta all'handler forzandolo in chat privata
+func (bot *Bot) SendMarkupResponseToPrivate(handler MessageHandler, m *markup.Builder, opt MessageResponseOpt) {
+	opt.HTMLformat = m.Mode() == markup.HTML
+	bot.sendResponseToPrivate(handler, m.String(), m.Plain(), opt)
+}
+
+// SendMessageResponse invia un messaggio di risposta all'handler; il testo deve essere
+// già formattato (HTML o MarkdownV2, vedi NewMarkup): se Telegram ne rifiuta
+// la formattazione viene inviato come testo semplice
 func (bot *Bot) SendMessageResponse(handler MessageHandler, text string, opt MessageResponseOpt) {
+	bot.sendResponse(handler, text, "", opt)
+}
+
+func (bot *Bot) sendResponse(handler MessageHandler, text string, plain string, opt MessageResponseOpt) {
 	var chatID int64
 
 	if opt.ForcePrivate && !handler.IsPrivate {
 		u, ok := bot.getUserByID(handler.UserID)
 		if !ok {
-			log.Println("Cannot send to private chat", handler, text)
+			bot.Logger().Warn("Cannot send to private chat", handler.logArgs()...)
 			return
 		}
 		chatID = u.PrivateChatID
@@ -93,14 +269,17 @@ func (bot *Bot) SendMessageResponse(handler MessageHandler, text string, opt Mes
 		msg := tgbotapi.NewEditMessageText(chatID, handler.EditMessageID, text)
 		if opt.HTMLformat {
 			msg.ParseMode = "HTML"
-			//msg.Text = html.EscapeString(msg.Text)
 		} else {
 			msg.ParseMode = "MarkdownV2"
 		}
 
 		msg.DisableWebPagePreview = !opt.LinksPreview
 
-		bot.Tgbot.Send(msg)
+		bot.sendFormatted(chatID, msg, plain)
+
+		if opt.DeleteAfter > 0 {
+			bot.deleteMessageAfter(chatID, handler.EditMessageID, opt.DeleteAfter)
+		}
 
 		if opt.KeyboardInline != nil /*|| opt.KeyboardReply != nil*/ {
 			var markup tgbotapi.InlineKeyboardMarkup
@@ -112,7 +291,7 @@ func (bot *Bot) SendMessageResponse(handler MessageHandler, text string, opt Mes
 			}*/
 
 			msg := tgbotapi.NewEditMessageReplyMarkup(chatID, handler.EditMessageID, markup)
-			bot.Tgbot.Send(msg)
+			bot.send(chatID, msg)
 		}
 
 	} else {
@@ -121,7 +300,6 @@ func (bot *Bot) SendMessageResponse(handler MessageHandler, text string, opt Mes
 		msg.ReplyToMessageID = replyMessageID
 		if opt.HTMLformat {
 			msg.ParseMode = "HTML"
-			//msg.Text = html.EscapeString(msg.Text)
 		} else {
 			msg.ParseMode = "MarkdownV2"
 		}
@@ -134,7 +312,14 @@ func (bot *Bot) SendMessageResponse(handler MessageHandler, text string, opt Mes
 			msg.ReplyMarkup = opt.KeyboardReply
 		}
 
-		newmsg, _ := bot.Tgbot.Send(msg)
+		newmsg, err := bot.sendFormatted(chatID, msg, plain)
+		if err != nil {
+			return
+		}
+
+		if opt.DeleteAfter > 0 {
+			bot.deleteMessageAfter(chatID, newmsg.MessageID, opt.DeleteAfter)
+		}
 
 		if opt.ReplaceSenderMessage {
 			cfg := tgbotapi.DeleteMessageConfig{
@@ -143,52 +328,108 @@ func (bot *Bot) SendMessageResponse(handler MessageHandler, text string, opt Mes
 			}
 
 			bot.Tgbot.DeleteMessage(cfg)
-		} else {
-			// indicizza il messaggio inviato nella lookup
-			bot.sentMessages.locker.Lock()
-			defer bot.sentMessages.locker.Unlock()
-
-			bot.sentMessages.lookupSenderSent[handler.MessageID] = newmsg.MessageID
-			bot.sentMessages.lookupSent[bot.sentMessages.sentCounter] = handler.MessageID
-
-			bot.sentMessages.sentCounter++
-			// copie esplicite necessarie perchè le map non ritornano memoria dopo i delete
-			if bot.sentMessages.sentCounter == gcMaxSentMessages*2 {
-				//fmt.Println("lookup", bot.sentMessages.sentCounter, bot.sentMessages.lookupSent, bot.sentMessages.lookupSenderSent)
-
-				// copia la seconda metà di gcMaxSentMessages
-				newmap := make(map[int]int)
-				for _, v := range bot.sentMessages.lookupSent[gcMaxSentMessages:] {
-					newmap[v] = bot.sentMessages.lookupSenderSent[v]
-				}
-				bot.sentMessages.lookupSenderSent = newmap
-
-				newarr := make([]int, gcMaxSentMessages*2)
-				copy(newarr, bot.sentMessages.lookupSent[gcMaxSentMessages:])
-				bot.sentMessages.lookupSent = newarr
-
-				bot.sentMessages.sentCounter = gcMaxSentMessages
-				//fmt.Println("lookup post", bot.sentMessages.sentCounter, bot.sentMessages.lookupSent, bot.sentMessages.lookupSenderSent)
+		} else if indexedResponse(opt) {
+			bot.sentMessages.add(responseIndex(handler, messageKey{chatID, newmsg.MessageID}, opt))
+		}
+	}
+}
+
+// le risposte effimere o che sostituiscono il mittente non vengono indicizzate nella lookup
+func indexedResponse(opt MessageResponseOpt) bool {
+	return opt.DeleteAfter == 0 && !opt.ReplaceSenderMessage
+}
+
+// chiave e voce della lookup per la risposta inviata. La copia in chat privata
+// di una richiesta arrivata da un gruppo è indicizzata con la propria chiave:
+// non è legata al messaggio mittente (nè alla sua eliminazione), ma può essere
+// eliminata da /purge nella chat privata.
+func responseIndex(handler MessageHandler, response messageKey, opt MessageResponseOpt) (messageKey, sentMessage) {
+	if response.ChatID != handler.ChatID {
+		return response, sentMessage{messageKey: response}
+	}
+
+	return messageKey{handler.ChatID, handler.MessageID}, sentMessage{
+		messageKey:       response,
+		deleteWithSender: opt.DeleteWithSender,
+	}
+}
+
+// indicizza il messaggio inviato in risposta al messaggio mittente
+func (lookups *sentMessagesLookups) add(senderKey messageKey, sent sentMessage) {
+	lookups.locker.Lock()
+	defer lookups.locker.Unlock()
+
+	lookups.lookupSenderSent[senderKey] = sent
+	lookups.lookupSent[lookups.sentCounter] = senderKey
+
+	lookups.sentCounter++
+	// copie esplicite necessarie perchè le map non ritornano memoria dopo i delete
+	if lookups.sentCounter == gcMaxSentMessages*2 {
+		// copia la seconda metà di gcMaxSentMessages
+		newmap := make(map[messageKey]sentMessage)
+		for _, v := range lookups.lookupSent[gcMaxSentMessages:] {
+			if sent, ok := lookups.lookupSenderSent[v]; ok {
+				newmap[v] = sent
 			}
 		}
+		lookups.lookupSenderSent = newmap
+
+		newarr := make([]messageKey, gcMaxSentMessages*2)
+		copy(newarr, lookups.lookupSent[gcMaxSentMessages:])
+		lookups.lookupSent = newarr
+
+		lookups.sentCounter = gcMaxSentMessages
+	}
+}
+
+// rimuove dalla lookup e ritorna le ultime n risposte inviate nella chat, dalla più recente
+func (lookups *sentMessagesLookups) take(chatID int64, n int) []messageKey {
+	lookups.locker.Lock()
+	defer lookups.locker.Unlock()
+
+	taken := []messageKey{}
+	for i := lookups.sentCounter - 1; i >= 0 && len(taken) < n; i-- {
+		senderKey := lookups.lookupSent[i]
+
+		sent, ok := lookups.lookupSenderSent[senderKey]
+		if !ok || sent.ChatID != chatID {
+			continue
+		}
+
+		delete(lookups.lookupSenderSent, senderKey)
+		taken = append(taken, sent.messageKey)
 	}
+
+	return taken
 }
 
 // SendMessageResponseToPrivate invia un messaggio di risposta all'handler forzandolo in chat privata
 func (bot *Bot) SendMessageResponseToPrivate(handler MessageHandler, text string, opt MessageResponseOpt) {
+	bot.sendResponseToPrivate(handler, text, "", opt)
+}
+
+func (bot *Bot) sendResponseToPrivate(handler MessageHandler, text string, plain string, opt MessageResponseOpt) {
 	opt.ForcePrivate = true
-	bot.SendMessageResponse(handler, text, opt)
+	bot.sendResponse(handler, text, plain, opt)
 
 	if !handler.IsPrivate {
+		// l'avviso è effimero: non va editato nè indicizzato
+		handler.EditMessageID = 0
 		opt.ForcePrivate = false
 		opt.ReplyToSenderMessage = true
+		opt.DeleteAfter = ephemeralDeleteAfter
 		bot.SendMessageResponse(handler, "pvt", opt)
 	}
 }
 
 func (bot *Bot) initMessages() {
-	bot.sentMessages.lookupSenderSent = make(map[int]int)
-	bot.sentMessages.lookupSent = make([]int, gcMaxSentMessages*2)
+	bot.sentMessages.init()
+}
+
+func (lookups *sentMessagesLookups) init() {
+	lookups.lookupSenderSent = make(map[messageKey]sentMessage)
+	lookups.lookupSent = make([]messageKey, gcMaxSentMessages*2)
+	lookups.sentCounter = 0
 }
 
 func (bot *Bot) ProcessMessage(handler MessageHandler, text string) (bool, error) {
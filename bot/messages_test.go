@@ -0,0 +1,114 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIndexedResponse(t *testing.T) {
+	tests := []struct {
+		opt  MessageResponseOpt
+		want bool
+	}{
+		{MessageResponseOpt{}, true},
+		{MessageResponseOpt{DeleteWithSender: true}, true},
+		{MessageResponseOpt{DeleteAfter: ephemeralDeleteAfter}, false},
+		{MessageResponseOpt{DeleteAfter: time.Second, DeleteWithSender: true}, false},
+		{MessageResponseOpt{ReplaceSenderMessage: true}, false},
+	}
+
+	for i, test := range tests {
+		if got := indexedResponse(test.opt); got != test.want {
+			t.Errorf("%d: indexedResponse() = %v, want %v", i, got, test.want)
+		}
+	}
+}
+
+func TestResponseIndex(t *testing.T) {
+	group := MessageHandler{ChatID: -100, MessageID: 5, UserID: 10}
+	private := MessageHandler{ChatID: 10, MessageID: 5, UserID: 10, IsPrivate: true}
+	opt := MessageResponseOpt{DeleteWithSender: true}
+
+	tests := []struct {
+		handler  MessageHandler
+		response messageKey
+		wantKey  messageKey
+		wantSent sentMessage
+	}{
+		{group, messageKey{-100, 6}, messageKey{-100, 5}, sentMessage{messageKey{-100, 6}, true}},
+		{private, messageKey{10, 6}, messageKey{10, 5}, sentMessage{messageKey{10, 6}, true}},
+		// copia in privato della risposta ad un gruppo: indicizzata con la propria chiave
+		{group, messageKey{10, 7}, messageKey{10, 7}, sentMessage{messageKey{10, 7}, false}},
+	}
+
+	for i, test := range tests {
+		key, sent := responseIndex(test.handler, test.response, opt)
+		if key != test.wantKey || sent != test.wantSent {
+			t.Errorf("%d: responseIndex() = %v, %v, want %v, %v", i, key, sent, test.wantKey, test.wantSent)
+		}
+	}
+}
+
+func TestSentMessagesLookups(t *testing.T) {
+	lookups := sentMessagesLookups{}
+	lookups.init()
+
+	// stesso ID del messaggio mittente in due chat diverse
+	lookups.add(messageKey{1, 10}, sentMessage{messageKey: messageKey{1, 11}})
+	lookups.add(messageKey{2, 10}, sentMessage{messageKey: messageKey{2, 11}, deleteWithSender: true})
+	lookups.add(messageKey{1, 12}, sentMessage{messageKey: messageKey{1, 13}})
+
+	if sent := lookups.lookupSenderSent[messageKey{1, 10}]; sent.MessageID != 11 || sent.deleteWithSender {
+		t.Error("Unexpected response in chat 1:", sent)
+	}
+	if sent := lookups.lookupSenderSent[messageKey{2, 10}]; sent.ChatID != 2 || !sent.deleteWithSender {
+		t.Error("Unexpected response in chat 2:", sent)
+	}
+
+	tests := []struct {
+		chatID int64
+		n      int
+		want   []messageKey
+	}{
+		{1, 1, []messageKey{{1, 13}}},
+		{1, 5, []messageKey{{1, 11}}},
+		{1, 5, []messageKey{}},
+		{3, 5, []messageKey{}},
+		{2, 5, []messageKey{{2, 11}}},
+	}
+
+	for i, test := range tests {
+		if got := lookups.take(test.chatID, test.n); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%d: take(%d, %d) = %v, want %v", i, test.chatID, test.n, got, test.want)
+		}
+	}
+}
+
+func TestSentMessagesLookupsGC(t *testing.T) {
+	lookups := sentMessagesLookups{}
+	lookups.init()
+
+	for i := 0; i < gcMaxSentMessages*2; i++ {
+		lookups.add(messageKey{1, i}, sentMessage{messageKey: messageKey{1, 1000 + i}})
+	}
+
+	// viene conservata soltanto la seconda metà dei messaggi indicizzati
+	if lookups.sentCounter != gcMaxSentMessages || len(lookups.lookupSenderSent) != gcMaxSentMessages {
+		t.Fatal("Unexpected lookup size:", lookups.sentCounter, len(lookups.lookupSenderSent))
+	}
+	if _, ok := lookups.lookupSenderSent[messageKey{1, gcMaxSentMessages - 1}]; ok {
+		t.Error("First half not collected")
+	}
+	if _, ok := lookups.lookupSenderSent[messageKey{1, gcMaxSentMessages}]; !ok {
+		t.Error("Second half collected")
+	}
+
+	lookups.add(messageKey{1, 5000}, sentMessage{messageKey: messageKey{1, 6000}})
+
+	got := lookups.take(1, 2)
+	want := []messageKey{{1, 6000}, {1, 1000 + gcMaxSentMessages*2 - 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("take() after gc = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"strings"
+	"time"
+
+	"github.com/marcozaccari/AssistantBot/metrics"
+)
+
+// prefisso dei nomi delle metriche
+const metricsNamespace = "assistantbot_"
+
+// etichetta dei comandi non dichiarati dal processore o non processati
+const metricsUnknownCommand = "unknown"
+
+// metriche del bot, esportate su /metrics se è configurato HTTPListen
+type botMetrics struct {
+	updates           *metrics.Counter
+	updateDuration    *metrics.Histogram
+	commands          *metrics.Counter   // processor, command
+	messages          *metrics.Counter   // processor
+	processorDuration *metrics.Histogram // processor, kind
+	processorErrors   *metrics.Counter   // processor, kind
+	sent              *metrics.Counter
+	sendErrors        *metrics.Counter
+	firewallDrops     *metrics.Counter // reason
+}
+
+func (bot *Bot) initMetrics() {
+	r := metrics.NewRegistry()
+	bot.metricsRegistry = r
+
+	r.GaugeFunc(metricsNamespace+"updates_backlog", "Updates received and waiting to be processed",
+		func() float64 { return float64(bot.backlog()) })
+
+	bot.metrics = botMetrics{
+		updates: r.Counter(metricsNamespace+"updates_total",
+			"Updates received from Telegram"),
+		updateDuration: r.Histogram(metricsNamespace+"update_duration_seconds",
+			"Time spent processing an update", nil),
+		commands: r.Counter(metricsNamespace+"commands_total",
+			"Commands processed, by processor", "processor", "command"),
+		messages: r.Counter(metricsNamespace+"messages_total",
+			"Plain messages processed, by processor", "processor"),
+		processorDuration: r.Histogram(metricsNamespace+"processor_duration_seconds",
+			"Time spent in processor calls", nil, "processor", "kind"),
+		processorErrors: r.Counter(metricsNamespace+"processor_errors_total",
+			"Errors returned by processors", "processor", "kind"),
+		sent: r.Counter(metricsNamespace+"messages_sent_total",
+			"Messages sent or edited"),
+		sendErrors: r.Counter(metricsNamespace+"send_errors_total",
+			"Messages rejected by Telegram"),
+		firewallDrops: r.Counter(metricsNamespace+"firewall_drops_total",
+			"Updates dropped by the firewall", "reason"),
+	}
+}
+
+// Metrics - registro delle metriche, in cui i processori possono registrare le proprie
+func (bot *Bot) Metrics() *metrics.Registry {
+	return bot.metricsRegistry
+}
+
+// etichetta "command" di commands_total: soltanto i comandi dichiarati dal processore
+// (Commands o Help), dato che il testo dei comandi è libero e la cardinalità va limitata
+func (bot *Bot) commandLabel(processor string, command string) string {
+	for _, c := range bot.commandsInfo() {
+		if c.processor == processor && strings.EqualFold(c.Command, command) {
+			return c.Command
+		}
+	}
+
+	return metricsUnknownCommand
+}
+
+// registra durata ed eventuale errore di una chiamata ad un processore
+// (kind: update, command, message)
+func (bot *Bot) observeProcessor(name string, kind string, start time.Time, err error) {
+	bot.metrics.processorDuration.Observe(time.Since(start).Seconds(), name, kind)
+	if err != nil {
+		bot.metrics.processorErrors.Inc(name, kind)
+		bot.recordProcessorError(name, err)
+	}
+}
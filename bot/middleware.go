@@ -0,0 +1,103 @@
+package bot
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Tipi di dispatch attraversati dai middleware (Context.Kind)
+const (
+	DispatchUpdate  = "update"  // ogni update ricevuta, prima dei processori
+	DispatchCommand = "command" // comando riconosciuto, prima di ProcessCommand
+	DispatchMessage = "message" // messaggio semplice, prima di ProcessMessage
+)
+
+// Context - update in elaborazione, passata lungo la catena dei middleware.
+// I middleware possono modificarne i campi (es. Params o Text) prima di
+// invocare il successivo.
+type Context struct {
+	Bot    *Bot
+	Kind   string // DispatchUpdate, DispatchCommand o DispatchMessage
+	Update tgbotapi.Update
+
+	// valorizzati per comandi e messaggi
+	Message *tgbotapi.Message
+	Handler MessageHandler
+
+	Command string   // solo comandi
+	Params  []string // solo comandi
+	Text    string   // solo messaggi
+
+	values map[string]interface{}
+}
+
+// campi che identificano l'update nei log
+func (ctx *Context) logArgs() []interface{} {
+	if ctx.Message == nil {
+		return []interface{}{"update_id", ctx.Update.UpdateID}
+	}
+
+	args := ctx.Handler.logArgs()
+	if ctx.Kind == DispatchCommand {
+		args = append(args, "command", ctx.Command)
+	}
+	return args
+}
+
+// chat dell'update, se presente
+func (ctx *Context) chatID() (int64, bool) {
+	if ctx.Message != nil {
+		return ctx.Message.Chat.ID, true
+	}
+
+	switch {
+	case ctx.Update.Message != nil:
+		return ctx.Update.Message.Chat.ID, true
+	case ctx.Update.EditedMessage != nil:
+		return ctx.Update.EditedMessage.Chat.ID, true
+	case ctx.Update.CallbackQuery != nil && ctx.Update.CallbackQuery.Message != nil:
+		return ctx.Update.CallbackQuery.Message.Chat.ID, true
+	case ctx.Update.ChannelPost != nil:
+		return ctx.Update.ChannelPost.Chat.ID, true
+	}
+
+	return 0, false
+}
+
+// Set - memorizza un valore condiviso tra i middleware e i processori
+func (ctx *Context) Set(key string, value interface{}) {
+	if ctx.values == nil {
+		ctx.values = make(map[string]interface{})
+	}
+	ctx.values[key] = value
+}
+
+// Value - ritorna un valore memorizzato con Set
+func (ctx *Context) Value(key string) (interface{}, bool) {
+	value, ok := ctx.values[key]
+	return value, ok
+}
+
+// Handler - elabora il contesto; ritorna true se è stato processato,
+// con la stessa semantica dei metodi di Processor
+type Handler func(ctx *Context) (bool, error)
+
+// Middleware - avvolge un Handler; può interrompere la catena ritornando
+// senza invocare next (es. return true, nil per scartare l'update)
+type Middleware func(next Handler) Handler
+
+// Use - aggiunge dei middleware, eseguiti nell'ordine di registrazione attorno
+// al dispatch di update, comandi e messaggi (vedi Context.Kind).
+// Va invocata prima di Do.
+func (bot *Bot) Use(middlewares ...Middleware) {
+	bot.middlewares = append(bot.middlewares, middlewares...)
+}
+
+// esegue final attraverso la catena dei middleware
+func (bot *Bot) dispatch(ctx *Context, final Handler) (bool, error) {
+	h := final
+	for i := len(bot.middlewares) - 1; i >= 0; i-- {
+		h = bot.middlewares[i](h)
+	}
+
+	return h(ctx)
+}
@@ -0,0 +1,91 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// middleware che registra le invocazioni in calls e interrompe la catena
+// per il tipo di dispatch stop
+func recordMiddleware(name string, stop string, calls *[]string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *Context) (bool, error) {
+			*calls = append(*calls, name+":"+ctx.Kind)
+			if ctx.Kind == stop {
+				return true, nil
+			}
+			return next(ctx)
+		}
+	}
+}
+
+func TestMiddlewares(t *testing.T) {
+	tests := []struct {
+		text   string
+		stopM1 string
+		stopM2 string
+		want   []string
+	}{
+		{
+			"/test a b", "", "",
+			[]string{"M1:update", "M2:update", "P:update", "M1:command", "M2:command", "P:command"},
+		},
+		{
+			"hello", "", "",
+			[]string{"M1:update", "M2:update", "P:update", "M1:message", "M2:message", "P:message"},
+		},
+		// l'update scartata non raggiunge il secondo middleware nè i processori
+		{
+			"/test", DispatchUpdate, "",
+			[]string{"M1:update"},
+		},
+		{
+			"/test", "", DispatchUpdate,
+			[]string{"M1:update", "M2:update"},
+		},
+		// il comando interrotto risulta processato: non viene trattato come messaggio
+		{
+			"/test", "", DispatchCommand,
+			[]string{"M1:update", "M2:update", "P:update", "M1:command", "M2:command"},
+		},
+		{
+			"hello", DispatchMessage, "",
+			[]string{"M1:update", "M2:update", "P:update", "M1:message"},
+		},
+	}
+
+	for i, test := range tests {
+		var calls []string
+
+		bot := newTestBot(t)
+		bot.RegisterProcessorWithOpt("P", &testProcessor{name: "P", process: "command,message", calls: &calls},
+			nil, ProcessorOpt{Priority: 1})
+		bot.Use(recordMiddleware("M1", test.stopM1, &calls), recordMiddleware("M2", test.stopM2, &calls))
+
+		if err := bot.LoadConfig(); err != nil {
+			t.Fatal("Error loading config:", err)
+		}
+		bot.lookupUsers = usersLookupMap{10: &user{ID: 10}}
+
+		message := &tgbotapi.Message{
+			MessageID: 1,
+			From:      &tgbotapi.User{ID: 10},
+			Chat:      &tgbotapi.Chat{ID: -100, Type: "group"},
+			Text:      test.text,
+		}
+		if test.text[0] == '/' {
+			message.Entities = &[]tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len("/test")}}
+		}
+
+		ctx := &Context{Bot: bot, Kind: DispatchUpdate, Update: tgbotapi.Update{UpdateID: 1, Message: message}}
+		if _, err := bot.dispatch(ctx, bot.dispatchUpdate); err != nil {
+			t.Errorf("%d: dispatch error: %v", i, err)
+		}
+
+		if !reflect.DeepEqual(calls, test.want) {
+			t.Errorf("%d: calls = %v, want %v", i, calls, test.want)
+		}
+	}
+}
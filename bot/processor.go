@@ -1,7 +1,9 @@
 package bot
 
 import (
-	"log"
+	"sort"
+	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 )
@@ -17,14 +19,76 @@ type Processor interface {
 	ProcessMessage(handler MessageHandler, text string) (bool, error)
 }
 
+// ProcessorOpt - opzioni di registrazione di un processore
+type ProcessorOpt struct {
+	// Priority - i processori con priorità più alta vengono interpellati per primi;
+	// a parità di priorità vale l'ordine di registrazione per comandi e messaggi,
+	// quello inverso per le update. Il bot ha priorità 0; per le update è comunque
+	// l'ultimo, dato che a sua volta smista comandi e messaggi.
+	Priority int
+
+	// Observer - il processore riceve tutte le update, i comandi e i messaggi,
+	// prima degli altri processori, ma non li consuma mai: il valore ritornato
+	// viene ignorato e gli eventuali errori vengono soltanto registrati
+	Observer bool
+}
+
+type processorEntry struct {
+	name      string
+	processor Processor
+	opt       ProcessorOpt
+}
+
+// insieme di processori; i nomi non distinguono maiuscole e minuscole
+// (vedi processorKey), come nel comando /processor
+type processorSet map[string]bool
+
+// chiave di un processore negli elenchi dei processori disattivati
+func processorKey(name string) string {
+	return strings.ToLower(name)
+}
+
+func (set processorSet) add(name string) {
+	set[processorKey(name)] = true
+}
+
+func (set processorSet) has(name string) bool {
+	return set[processorKey(name)]
+}
+
+// processori disattivati globalmente o nella chat (vedi /processor disable)
+func (bot *Bot) disabledProcessors(chatID int64) processorSet {
+	disabled := make(processorSet)
+
+	bot.ViewConfig(func() {
+		for _, name := range bot.config.DisabledProcessors {
+			disabled.add(name)
+		}
+		for _, name := range bot.config.DisabledProcessorsByChat[chatID] {
+			disabled.add(name)
+		}
+	})
+
+	// il bot non è disattivabile
+	delete(disabled, processorKey(botProcessorName))
+
+	return disabled
+}
+
+// ConfigObserver - interfaccia opzionale dei processori, notificati quando la loro
+// configurazione registrata viene modificata da un reload o dal comando /config set.
+// old è una copia dei valori precedenti, new la struttura registrata (già aggiornata).
+type ConfigObserver interface {
+	OnConfigChanged(old interface{}, new interface{})
+}
+
 func (bot *Bot) ProcessUpdate(update tgbotapi.Update) (bool, error) {
 
-	if bot.Debug {
-		log.Printf("(new update) %+v %+v\n", update.Message, update.EditedMessage)
-	}
+	bot.Logger().Debug("New update", "update", describeUpdate(update))
 
 	var replyUserID int
 	var replyUsername string
+	var replyMessageID int
 
 	allowed, canProcessCommands := bot.allowedUpdate(&update)
 	if !allowed {
@@ -46,26 +110,35 @@ func (bot *Bot) ProcessUpdate(update tgbotapi.Update) (bool, error) {
 		return false, nil
 	}
 
+	bot.recordSeenUsers(message)
+
 	if message.ReplyToMessage != nil {
 		replyUserID = message.ReplyToMessage.From.ID
 		replyUsername = message.ReplyToMessage.From.UserName
+		replyMessageID = message.ReplyToMessage.MessageID
 	}
 
 	handler := MessageHandler{
-		UserID:        message.From.ID,
-		Username:      message.From.UserName,
-		ChatID:        message.Chat.ID,
-		IsPrivate:     message.Chat.IsPrivate(),
-		MessageID:     message.MessageID,
-		ReplyUserID:   replyUserID,
-		ReplyUsername: replyUsername,
+		UserID:         message.From.ID,
+		Username:       message.From.UserName,
+		ChatID:         message.Chat.ID,
+		IsPrivate:      message.Chat.IsPrivate(),
+		MessageID:      message.MessageID,
+		ReplyUserID:    replyUserID,
+		ReplyUsername:  replyUsername,
+		ReplyMessageID: replyMessageID,
+		LanguageCode:   message.From.LanguageCode,
+		Mentions:       bot.messageMentions(message),
 	}
+	handler.Language = bot.resolveLanguage(handler.UserID, handler.ChatID, handler.LanguageCode)
 	if edited {
-		handler.EditMessageID = bot.sentMessages.lookupSenderSent[message.MessageID]
+		if sent, ok := bot.lookupSentMessage(message.Chat.ID, message.MessageID); ok {
+			handler.EditMessageID = sent.MessageID
+		}
 	}
 
 	if canProcessCommands {
-		processed, err := bot.processMessageAsCommand(message, handler)
+		processed, err := bot.processMessageAsCommand(update, message, handler)
 		if err != nil {
 			return true, err
 		}
@@ -74,24 +147,41 @@ func (bot *Bot) ProcessUpdate(update tgbotapi.Update) (bool, error) {
 		}
 	}
 
-	if bot.config.ProcessGroupMessages && !bot.silenceOn {
-		// Delega i messaggi semplici ai processori.
-		// il primo che processa interrompe la coda.
-		for _, p := range bot.processors {
-			processed, err := p.ProcessMessage(handler, message.Text)
-			if err != nil {
-				return true, err
-			}
-			if processed {
-				break
-			}
+	if bot.getConfig().ProcessGroupMessages && !bot.silenceOn {
+		ctx := &Context{
+			Bot:     bot,
+			Kind:    DispatchMessage,
+			Update:  update,
+			Message: message,
+			Handler: handler,
+			Text:    message.Text,
+		}
+
+		_, err := bot.dispatch(ctx, bot.dispatchMessage)
+		if err != nil {
+			return true, err
 		}
 	}
 
 	return true, nil
 }
 
-func (bot *Bot) processMessageAsCommand(message *tgbotapi.Message, handler MessageHandler) (bool, error) {
+// Delega i messaggi semplici ai processori.
+// il primo che processa interrompe la coda.
+func (bot *Bot) dispatchMessage(ctx *Context) (bool, error) {
+	name, processed, err := bot.dispatchProcessors(bot.processors, DispatchMessage, ctx,
+		func(p Processor) (bool, error) {
+			return p.ProcessMessage(ctx.Handler, ctx.Text)
+		})
+
+	if processed && err == nil {
+		bot.metrics.messages.Inc(name)
+	}
+
+	return processed, err
+}
+
+func (bot *Bot) processMessageAsCommand(update tgbotapi.Update, message *tgbotapi.Message, handler MessageHandler) (bool, error) {
 	var command string
 	var params []string
 	var ok bool
@@ -102,9 +192,8 @@ func (bot *Bot) processMessageAsCommand(message *tgbotapi.Message, handler Messa
 		return false, nil
 	}
 
-	if bot.Debug {
-		log.Printf("(stack) Command from %s/%s: %s %v\n", message.Chat.Title, message.From.UserName, command, params)
-	}
+	// i parametri non vengono riportati: possono contenere dati riservati (es. /owner token)
+	bot.Logger().Debug("Command", append(handler.logArgs(), "command", command, "params", len(params))...)
 
 	u, ok := bot.getUserByID(message.From.ID)
 	if ok {
@@ -112,25 +201,111 @@ func (bot *Bot) processMessageAsCommand(message *tgbotapi.Message, handler Messa
 
 		// risincronizza se necessario i dati dell'utente
 		if u.Username != message.From.UserName {
-			bot.updateUsername(u, message.From.UserName)
+			bot.updateUsername(u.ID, message.From.UserName)
 		}
 		if handler.IsPrivate &&
 			(u.PrivateChatID != message.Chat.ID) {
-			bot.updateUserPrivateChatID(u, message.Chat.ID)
+			bot.updateUserPrivateChatID(u.ID, message.Chat.ID)
+
+			// owner e admin vedono i propri comandi nel menu della chat privata
+			if u.Group != "" {
+				bot.refreshCommands()
+			}
 		}
 	}
 
-	// Delega i comandi ai processori.
-	// il primo che processa interrompe la coda.
-	for _, p := range bot.processors {
-		processed, err := p.ProcessCommand(handler, command, params)
-		if err != nil {
-			return true, err
-		}
+	ctx := &Context{
+		Bot:     bot,
+		Kind:    DispatchCommand,
+		Update:  update,
+		Message: message,
+		Handler: handler,
+		Command: command,
+		Params:  params,
+	}
+
+	return bot.dispatch(ctx, bot.dispatchCommand)
+}
+
+// Delega i comandi ai processori.
+// il primo che processa interrompe la coda.
+func (bot *Bot) dispatchCommand(ctx *Context) (bool, error) {
+	name, processed, err := bot.dispatchProcessors(bot.processors, DispatchCommand, ctx,
+		func(p Processor) (bool, error) {
+			return p.ProcessCommand(ctx.Handler, ctx.Command, ctx.Params)
+		})
+
+	if err == nil {
 		if processed {
-			return true, nil
+			bot.metrics.commands.Inc(name, bot.commandLabel(name, ctx.Command))
+			bot.Logger().Debug("Command processed", append(ctx.logArgs(), "processor", name)...)
+		} else {
+			bot.metrics.commands.Inc("", metricsUnknownCommand)
+		}
+	}
+
+	return processed, err
+}
+
+// ordine di dispatch delle update: priorità decrescente e, a parità di priorità,
+// ordine inverso di registrazione (come nelle versioni precedenti); il bot in coda
+func (bot *Bot) updateOrder() []*processorEntry {
+	order := make([]*processorEntry, 0, len(bot.processors))
+	var self *processorEntry
+
+	for i := len(bot.processors) - 1; i >= 0; i-- {
+		e := bot.processors[i]
+		if e.processor == Processor(bot) {
+			self = e
+			continue
+		}
+		order = append(order, e)
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return order[i].opt.Priority > order[j].opt.Priority
+	})
+
+	if self != nil {
+		order = append(order, self)
+	}
+
+	return order
+}
+
+// interpella i processori in order: prima tutti gli osservatori, poi gli altri
+// finchè uno non processa. Ritorna il nome del processore che ha processato.
+func (bot *Bot) dispatchProcessors(order []*processorEntry, kind string, ctx *Context,
+	call func(p Processor) (bool, error)) (string, bool, error) {
+
+	chatID, _ := ctx.chatID()
+	disabled := bot.disabledProcessors(chatID)
+
+	for _, observers := range []bool{true, false} {
+		for _, e := range order {
+			if e.opt.Observer != observers || disabled.has(e.name) {
+				continue
+			}
+
+			start := time.Now()
+			processed, err := call(e.processor)
+			bot.observeProcessor(e.name, kind, start, err)
+
+			if err != nil {
+				bot.Logger().Error("Cannot process "+kind, append(ctx.logArgs(),
+					"processor", e.name, "err", bot.redactError(err))...)
+
+				if observers {
+					continue
+				}
+				return e.name, true, err
+			}
+
+			if processed && !observers {
+				return e.name, true, nil
+			}
 		}
 	}
 
-	return false, nil
+	return "", false, nil
 }
@@ -0,0 +1,207 @@
+package bot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// processore che registra le invocazioni in calls
+type testProcessor struct {
+	StubProcessor
+	name    string
+	process string // tipi di dispatch processati (es. "update,command")
+	calls   *[]string
+	help    string
+}
+
+func (p *testProcessor) Help() string {
+	return p.help
+}
+
+func (p *testProcessor) record(kind string) bool {
+	*p.calls = append(*p.calls, p.name+":"+kind)
+	return strings.Contains(p.process, kind)
+}
+
+func (p *testProcessor) ProcessUpdate(update tgbotapi.Update) (bool, error) {
+	return p.record(DispatchUpdate), nil
+}
+
+func (p *testProcessor) ProcessCommand(handler MessageHandler, command string, params []string) (bool, error) {
+	return p.record(DispatchCommand), nil
+}
+
+func (p *testProcessor) ProcessMessage(handler MessageHandler, text string) (bool, error) {
+	return p.record(DispatchMessage), nil
+}
+
+// bot con configurazione di default in una directory temporanea
+func newTestBot(t *testing.T) *Bot {
+	dir, err := ioutil.TempDir("", "bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	filename := filepath.Join(dir, "settings.json")
+	err = ioutil.WriteFile(filename, DefaultConfigContent, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bot := NewBot(filename, false, false)
+	bot.StateFilename = "state.json"
+	t.Cleanup(func() {
+		if bot.store != nil {
+			bot.store.Close()
+		}
+	})
+
+	return bot
+}
+
+func processorNames(order []*processorEntry) string {
+	names := make([]string, len(order))
+	for i, e := range order {
+		names[i] = e.name
+	}
+	return strings.Join(names, ",")
+}
+
+func TestProcessorsOrder(t *testing.T) {
+	type registration struct {
+		name string
+		opt  ProcessorOpt
+	}
+
+	tests := []struct {
+		processors []registration
+		commands   string
+		updates    string
+	}{
+		{
+			[]registration{{"A", ProcessorOpt{}}, {"B", ProcessorOpt{}}},
+			"Bot,A,B",
+			"B,A,Bot",
+		},
+		{
+			[]registration{{"A", ProcessorOpt{}}, {"B", ProcessorOpt{Priority: 5}}, {"C", ProcessorOpt{Priority: 5}}},
+			"B,C,Bot,A",
+			"C,B,A,Bot",
+		},
+		{
+			[]registration{{"A", ProcessorOpt{Priority: -1}}, {"B", ProcessorOpt{}}, {"C", ProcessorOpt{Priority: 1}}},
+			"C,Bot,B,A",
+			"C,B,A,Bot",
+		},
+	}
+
+	for i, test := range tests {
+		bot := newTestBot(t)
+		for _, r := range test.processors {
+			bot.RegisterProcessorWithOpt(r.name, &StubProcessor{}, nil, r.opt)
+		}
+
+		if got := processorNames(bot.processors); got != test.commands {
+			t.Errorf("%d: commands order = %s, want %s", i, got, test.commands)
+		}
+		if got := processorNames(bot.updateOrder()); got != test.updates {
+			t.Errorf("%d: updates order = %s, want %s", i, got, test.updates)
+		}
+	}
+}
+
+func TestDispatchProcessors(t *testing.T) {
+	var calls []string
+
+	bot := newTestBot(t)
+	bot.RegisterProcessor("A", &testProcessor{name: "A", process: "update,command,message", calls: &calls}, nil)
+	bot.RegisterProcessor("B", &testProcessor{name: "B", process: "update,command,message", calls: &calls}, nil)
+	bot.RegisterProcessorWithOpt("C", &testProcessor{name: "C", calls: &calls}, nil, ProcessorOpt{Priority: 1})
+	bot.RegisterProcessorWithOpt("O", &testProcessor{name: "O", process: "update,command,message", calls: &calls}, nil, ProcessorOpt{Observer: true})
+
+	message := &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 1}}
+
+	tests := []struct {
+		ctx  *Context
+		want []string
+	}{
+		// gli osservatori ricevono tutto; il primo che processa interrompe la coda
+		{&Context{Kind: DispatchUpdate}, []string{"O:update", "C:update", "B:update"}},
+		{&Context{Kind: DispatchCommand, Message: message}, []string{"O:command", "C:command", "A:command"}},
+		{&Context{Kind: DispatchMessage, Message: message}, []string{"O:message", "C:message", "A:message"}},
+	}
+
+	for i, test := range tests {
+		calls = nil
+		test.ctx.Bot = bot
+
+		var final Handler
+		switch test.ctx.Kind {
+		case DispatchUpdate:
+			final = bot.dispatchUpdate
+		case DispatchCommand:
+			final = bot.dispatchCommand
+		default:
+			final = bot.dispatchMessage
+		}
+
+		processed, err := bot.dispatch(test.ctx, final)
+		if !processed || err != nil {
+			t.Errorf("%d: dispatch() = %v, %v", i, processed, err)
+		}
+		if !reflect.DeepEqual(calls, test.want) {
+			t.Errorf("%d: calls = %v, want %v", i, calls, test.want)
+		}
+	}
+}
+
+func TestCommandMetrics(t *testing.T) {
+	var calls []string
+
+	p := &testProcessor{name: "P", process: "command", calls: &calls, help: "/mycommand  Test"}
+
+	bot := newTestBot(t)
+	bot.RegisterProcessor("P", p, nil)
+	if err := bot.LoadConfig(); err != nil {
+		t.Fatal("Error loading config:", err)
+	}
+
+	for _, command := range []string{"mycommand", "MyCommand", "random1", "random2"} {
+		ctx := &Context{Bot: bot, Kind: DispatchCommand, Command: command}
+		if _, err := bot.dispatchCommand(ctx); err != nil {
+			t.Fatal("Dispatch error:", err)
+		}
+	}
+
+	// i comandi non processati finiscono nella stessa etichetta
+	p.process = ""
+	ctx := &Context{Bot: bot, Kind: DispatchCommand, Command: "random3"}
+	if _, err := bot.dispatchCommand(ctx); err != nil {
+		t.Fatal("Dispatch error:", err)
+	}
+
+	tests := []struct {
+		processor string
+		command   string
+		want      float64
+	}{
+		{"P", "mycommand", 2},
+		{"P", metricsUnknownCommand, 2},
+		{"P", "random1", 0},
+		{"", metricsUnknownCommand, 1},
+		{"", "random3", 0},
+	}
+
+	for i, test := range tests {
+		if got := bot.metrics.commands.Value(test.processor, test.command); got != test.want {
+			t.Errorf("%d: commands(%q, %q) = %v, want %v", i, test.processor, test.command, got, test.want)
+		}
+	}
+}
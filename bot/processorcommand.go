@@ -0,0 +1,220 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func (bot *Bot) processProcessorCommand(handler MessageHandler, params []string) {
+	if handler.Group != groupOwner && handler.Group != groupAdmin {
+		bot.logNoPermission(handler, commandProcessor)
+		return
+	}
+
+	var text string
+	tr := bot.translator(handler.Language)
+
+	if len(params) == 0 {
+		text = tr("bot.processor.help")
+	} else {
+		switch params[0] {
+		case "list":
+			text = bot.processorsListText(tr, handler.ChatID)
+		case "order":
+			text = bot.dispatchOrderText(tr)
+		case "enable", "disable":
+			text = bot.toggleProcessor(handler, params[0] == "enable", params[1:])
+		default:
+			text = tr("bot.unknownSubcommand", html.EscapeString(params[0])) + "\n" + tr("bot.processor.help")
+		}
+	}
+
+	opt := bot.NewMessageResponseOpt()
+	bot.SendMessageResponse(handler, text, opt)
+}
+
+// ordine effettivo di dispatch, per tipo
+func (bot *Bot) dispatchOrderText(tr translator) string {
+	describe := func(order []*processorEntry) string {
+		var text string
+		var n int
+
+		for _, e := range order {
+			if e.opt.Observer {
+				continue
+			}
+			n++
+			text += fmt.Sprintf("%d. %s <code>%d</code>\n", n, html.EscapeString(e.name), e.opt.Priority)
+		}
+
+		return text
+	}
+
+	text := "<b>" + tr("bot.processor.updates") + "</b>\n" + describe(bot.updateOrder()) +
+		"\n<b>" + tr("bot.processor.commands") + "</b>\n" + describe(bot.processors)
+
+	var observers string
+	for _, e := range bot.processors {
+		if e.opt.Observer {
+			observers += html.EscapeString(e.name) + fmt.Sprintf(" <code>%d</code>\n", e.opt.Priority)
+		}
+	}
+	if observers != "" {
+		text += "\n<b>" + tr("bot.processor.observers") + "</b> " + tr("bot.processor.observersNote") + "\n" + observers
+	}
+
+	return text
+}
+
+// elenco dei processori con il loro stato
+func (bot *Bot) processorsListText(tr translator, chatID int64) string {
+	config := bot.processorsConfig()
+
+	var text string
+	for _, e := range bot.processors {
+		text += html.EscapeString(e.name) + " <code>" + html.EscapeString(e.processor.Version()) + "</code>"
+
+		if containsName(config.DisabledProcessors, e.name) {
+			text += " " + tr("bot.processor.isDisabled")
+		} else {
+			var chats []string
+			for id, names := range config.DisabledProcessorsByChat {
+				if containsName(names, e.name) {
+					chats = append(chats, strconv.FormatInt(id, 10))
+				}
+			}
+
+			if containsName(config.DisabledProcessorsByChat[chatID], e.name) {
+				text += " " + tr("bot.processor.isDisabledHere")
+			} else if len(chats) > 0 {
+				sort.Strings(chats)
+				text += " " + tr("bot.processor.isDisabledChats", strings.Join(chats, ", "))
+			}
+		}
+
+		text += "\n"
+	}
+
+	return text
+}
+
+// copia delle impostazioni dei processori disattivati
+func (bot *Bot) processorsConfig() configData {
+	var config configData
+
+	bot.ViewConfig(func() {
+		config.DisabledProcessors = append([]string{}, bot.config.DisabledProcessors...)
+		config.DisabledProcessorsByChat = make(map[int64][]string)
+		for id, names := range bot.config.DisabledProcessorsByChat {
+			config.DisabledProcessorsByChat[id] = append([]string{}, names...)
+		}
+	})
+
+	return config
+}
+
+// abilita o disabilita un processore globalmente o in una chat, salvando la configurazione
+func (bot *Bot) toggleProcessor(handler MessageHandler, enable bool, params []string) string {
+	tr := bot.translator(handler.Language)
+
+	if len(params) == 0 {
+		return tr("bot.processor.help")
+	}
+
+	var entry *processorEntry
+	for _, e := range bot.processors {
+		if processorKey(e.name) == processorKey(params[0]) {
+			entry = e
+		}
+	}
+	if entry == nil {
+		return tr("bot.processor.unknown", html.EscapeString(params[0]))
+	}
+	if entry.name == botProcessorName {
+		return tr("bot.processor.botNotDisabled")
+	}
+
+	var chatID int64
+	perChat := len(params) > 1
+	if perChat {
+		if params[1] == "here" {
+			chatID = handler.ChatID
+		} else {
+			var err error
+			chatID, err = strconv.ParseInt(params[1], 10, 64)
+			if err != nil {
+				return tr("bot.processor.invalidChat", html.EscapeString(params[1]))
+			}
+		}
+	}
+
+	bot.UpdateConfig(func() {
+		if !perChat {
+			bot.config.DisabledProcessors = toggleName(bot.config.DisabledProcessors, entry.name, !enable)
+			return
+		}
+
+		if bot.config.DisabledProcessorsByChat == nil {
+			bot.config.DisabledProcessorsByChat = make(map[int64][]string)
+		}
+
+		names := toggleName(bot.config.DisabledProcessorsByChat[chatID], entry.name, !enable)
+		if len(names) == 0 {
+			delete(bot.config.DisabledProcessorsByChat, chatID)
+		} else {
+			bot.config.DisabledProcessorsByChat[chatID] = names
+		}
+	})
+
+	bot.refreshCommands()
+
+	bot.Logger().Info("Processor toggled", append(handler.logArgs(),
+		"processor", entry.name, "enabled", enable, "target_chat_id", chatID)...)
+
+	name := html.EscapeString(entry.name)
+
+	var text string
+	switch {
+	case perChat && enable:
+		text = tr("bot.processor.enabledChat", name, chatID)
+		if containsName(bot.processorsConfig().DisabledProcessors, entry.name) {
+			text += tr("bot.processor.stillDisabled")
+		}
+	case perChat:
+		text = tr("bot.processor.disabledChat", name, chatID)
+	case enable:
+		text = tr("bot.processor.enabled", name)
+	default:
+		text = tr("bot.processor.disabled", name)
+	}
+
+	return text
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if processorKey(n) == processorKey(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// aggiunge (add) o rimuove name dall'elenco, ritornando una nuova slice
+func toggleName(names []string, name string, add bool) []string {
+	result := []string{}
+
+	for _, n := range names {
+		if processorKey(n) != processorKey(name) {
+			result = append(result, n)
+		}
+	}
+	if add {
+		result = append(result, name)
+	}
+
+	return result
+}
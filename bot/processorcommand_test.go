@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func TestToggleProcessor(t *testing.T) {
+	var calls []string
+
+	bot := newTestBot(t)
+	bot.RegisterProcessor("myScope", &testProcessor{name: "MyScope", calls: &calls}, nil)
+	if err := bot.LoadConfig(); err != nil {
+		t.Fatal("Error loading config:", err)
+	}
+
+	handler := MessageHandler{ChatID: -100, Language: "en"}
+	tr := bot.translator(handler.Language)
+
+	tests := []struct {
+		params   []string
+		want     string
+		disabled []string
+		byChat   map[int64][]string
+	}{
+		{[]string{"myscope"}, tr("bot.processor.disabled", "MyScope"), []string{"MyScope"}, map[int64][]string{}},
+		{[]string{"MYSCOPE"}, tr("bot.processor.disabled", "MyScope"), []string{"MyScope"}, map[int64][]string{}},
+		{[]string{"myscope", "here"}, tr("bot.processor.disabledChat", "MyScope", -100),
+			[]string{"MyScope"}, map[int64][]string{-100: {"MyScope"}}},
+		{[]string{"myscope", "-200"}, tr("bot.processor.disabledChat", "MyScope", -200),
+			[]string{"MyScope"}, map[int64][]string{-100: {"MyScope"}, -200: {"MyScope"}}},
+		{[]string{"myscope", "x"}, tr("bot.processor.invalidChat", "x"),
+			[]string{"MyScope"}, map[int64][]string{-100: {"MyScope"}, -200: {"MyScope"}}},
+		{[]string{"bot"}, tr("bot.processor.botNotDisabled"),
+			[]string{"MyScope"}, map[int64][]string{-100: {"MyScope"}, -200: {"MyScope"}}},
+		{[]string{"other"}, tr("bot.processor.unknown", "other"),
+			[]string{"MyScope"}, map[int64][]string{-100: {"MyScope"}, -200: {"MyScope"}}},
+	}
+
+	for i, test := range tests {
+		if got := bot.toggleProcessor(handler, false, test.params); got != test.want {
+			t.Errorf("%d: toggleProcessor() = %q, want %q", i, got, test.want)
+		}
+
+		config := bot.processorsConfig()
+		if !reflect.DeepEqual(config.DisabledProcessors, test.disabled) ||
+			!reflect.DeepEqual(config.DisabledProcessorsByChat, test.byChat) {
+			t.Errorf("%d: disabled = %v %v, want %v %v", i, config.DisabledProcessors,
+				config.DisabledProcessorsByChat, test.disabled, test.byChat)
+		}
+	}
+
+	// riabilitato globalmente resta disattivato nelle chat
+	want := tr("bot.processor.enabledChat", "MyScope", -100) + tr("bot.processor.stillDisabled")
+	if got := bot.toggleProcessor(handler, true, []string{"myscope", "here"}); got != want {
+		t.Errorf("toggleProcessor() = %q, want %q", got, want)
+	}
+	bot.toggleProcessor(handler, true, []string{"myscope"})
+	bot.toggleProcessor(handler, true, []string{"myscope", "-200"})
+
+	config := bot.processorsConfig()
+	if len(config.DisabledProcessors) != 0 || len(config.DisabledProcessorsByChat) != 0 {
+		t.Error("Processor still disabled:", config.DisabledProcessors, config.DisabledProcessorsByChat)
+	}
+}
+
+func TestDisabledProcessor(t *testing.T) {
+	var calls []string
+
+	bot := newTestBot(t)
+	bot.RegisterProcessor("myScope", &testProcessor{name: "MyScope", process: "command", calls: &calls,
+		help: "/mycommand  My command"}, nil)
+	bot.RegisterProcessor("other", &testProcessor{name: "Other", process: "command", calls: &calls,
+		help: "/other  Other command"}, nil)
+	if err := bot.LoadConfig(); err != nil {
+		t.Fatal("Error loading config:", err)
+	}
+
+	// nomi scritti a mano nel file, con una grafia diversa da quella registrata
+	bot.UpdateConfig(func() {
+		bot.config.DisabledProcessors = []string{"myscope", "BOT"}
+	})
+
+	message := &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 1}}
+	ctx := &Context{Bot: bot, Kind: DispatchCommand, Message: message, Command: "mycommand"}
+	if _, err := bot.dispatchCommand(ctx); err != nil {
+		t.Fatal("Error dispatching command:", err)
+	}
+	if !reflect.DeepEqual(calls, []string{"Other:command"}) {
+		t.Error("Disabled processor not skipped:", calls)
+	}
+
+	help := bot.helpText("en", 1)
+	if strings.Contains(help, "/mycommand") || !strings.Contains(help, "/other") || !strings.Contains(help, "/ping") {
+		t.Error("Unexpected help:", help)
+	}
+
+	menu := menuCommands(bot.commandsInfo(), []CommandScope{CommandScopeAll}, bot.disabledProcessors(1), "")
+	var commands []string
+	for _, c := range menu {
+		commands = append(commands, c.Command)
+	}
+	if s := strings.Join(commands, ","); strings.Contains(s, "mycommand") || !strings.Contains(s, "other") ||
+		!strings.Contains(s, "help") {
+		t.Error("Unexpected menu:", s)
+	}
+}
@@ -0,0 +1,55 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/marcozaccari/AssistantBot/settings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// maschera il token nei testi destinati ai log (es. gli errori HTTP riportano l'URL delle API)
+func (bot *Bot) redact(s string) string {
+	token := bot.getConfig().SecureToken
+	if token == "" {
+		return s
+	}
+
+	return strings.Replace(s, token, settings.RedactedMask, -1)
+}
+
+func (bot *Bot) redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return errors.New(bot.redact(err.Error()))
+}
+
+// riassunto di un'update per i log di debug, senza il contenuto dei messaggi
+func describeUpdate(update tgbotapi.Update) string {
+	s := fmt.Sprint("update ", update.UpdateID)
+
+	message := update.Message
+	switch {
+	case update.EditedMessage != nil:
+		s += " edited"
+		message = update.EditedMessage
+	case update.CallbackQuery != nil:
+		s += fmt.Sprint(" callback from ", update.CallbackQuery.From.ID)
+	case update.InlineQuery != nil:
+		s += fmt.Sprint(" inline query from ", update.InlineQuery.From.ID)
+	}
+
+	if message != nil {
+		s += fmt.Sprint(" message ", message.MessageID, " chat ", message.Chat.ID)
+		if message.From != nil {
+			s += fmt.Sprint(" from ", message.From.ID)
+		}
+		s += fmt.Sprint(" text ", len(message.Text), " chars")
+	}
+
+	return s
+}
@@ -0,0 +1,267 @@
+package bot
+
+// Stato di runtime del bot (owner e utenti autorizzati), separato dalla
+// configurazione statica: il file di configurazione non viene più riscritto dal bot.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/marcozaccari/AssistantBot/storage"
+)
+
+// namespace dello stato del bot; i processori usano il proprio scope
+const stateNamespace = "Bot"
+
+const (
+	stateKeyOwner      = "owner"
+	stateKeyUserPrefix = "users/"
+)
+
+// estensione di default dello store (bbolt)
+const stateDefaultExt = ".state.db"
+
+// stato contenuto nella configurazione prima della versione 1 dello schema
+type legacyState struct {
+	OwnerID int
+	Users   []user
+}
+
+// nome del file dello stato: StateFilename se impostato, altrimenti
+// ricavato dal file di configurazione (es. settings.bot.json -> settings.bot.state.db)
+func (bot *Bot) stateFilename() string {
+	if bot.StateFilename != "" {
+		if filepath.IsAbs(bot.StateFilename) {
+			return bot.StateFilename
+		}
+		return filepath.Join(filepath.Dir(bot.configCtrl.Filename()), bot.StateFilename)
+	}
+
+	configFilename := bot.configCtrl.Filename()
+	return strings.TrimSuffix(configFilename, filepath.Ext(configFilename)) + stateDefaultExt
+}
+
+// apre lo store se non ancora aperto
+func (bot *Bot) openStore() (storage.Store, error) {
+	bot.storeLock.Lock()
+	defer bot.storeLock.Unlock()
+
+	if bot.store != nil {
+		return bot.store, nil
+	}
+
+	filename := bot.stateFilename()
+	bot.Logger().Info("State filename", "file", filename)
+
+	store, err := storage.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	bot.store = store
+	return store, nil
+}
+
+// ErrReservedScope - lo scope del bot (owner, utenti e preferenze) non è accessibile
+// ai processori tramite Storage
+var ErrReservedScope = errors.New("bot: storage scope reserved to the bot")
+
+// Storage - ritorna l'accesso ai dati di runtime riservati allo scope (lo stesso nome
+// usato in RegisterProcessor). I valori sono serializzati in JSON; User e Chat
+// restringono l'accesso ai dati di un singolo utente o di una singola chat.
+// Lo store viene aperto al primo utilizzo. Lo scope del bot è riservato (ErrReservedScope).
+func (bot *Bot) Storage(scope string) (*storage.Handle, error) {
+	if strings.EqualFold(scope, stateNamespace) {
+		return nil, ErrReservedScope
+	}
+
+	return bot.storage(strings.Title(scope))
+}
+
+// accesso allo stato del bot
+func (bot *Bot) stateStorage() (*storage.Handle, error) {
+	return bot.storage(stateNamespace)
+}
+
+func (bot *Bot) storage(namespace string) (*storage.Handle, error) {
+	store, err := bot.openStore()
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.NewHandle(storage.NewNamespace(store, namespace)), nil
+}
+
+// carica owner e utenti dallo store
+func (bot *Bot) loadState() error {
+	state, err := bot.stateStorage()
+	if err != nil {
+		return err
+	}
+
+	users := make(usersLookupMap)
+
+	keys, err := state.List(stateKeyUserPrefix)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		u := user{}
+		ok, err := state.Get(key, &u)
+		if err != nil {
+			return err
+		}
+		if ok {
+			users[u.ID] = &u
+		}
+	}
+
+	var ownerID int
+	_, err = state.Get(stateKeyOwner, &ownerID)
+	if err != nil {
+		return err
+	}
+
+	bot.stateLock.Lock()
+	bot.lookupUsers = users
+	bot.ownerID = ownerID
+	bot.stateLock.Unlock()
+
+	bot.logUsers()
+
+	return bot.loadSeenUsers()
+}
+
+// importa nello store owner e utenti presenti nella vecchia configurazione.
+// Se lo store contiene già dei dati questi prevalgono: gli utenti già presenti
+// e l'owner già impostato non vengono sovrascritti, ma segnalati nel log.
+func (bot *Bot) importLegacyState(legacy legacyState) error {
+	state, err := bot.stateStorage()
+	if err != nil {
+		return err
+	}
+
+	bot.Logger().Warn("Importing users from settings file", "file", bot.stateFilename())
+
+	for _, u := range legacy.Users {
+		key := stateKeyUserPrefix + strconv.Itoa(u.ID)
+
+		var stored user
+		ok, err := state.Get(key, &stored)
+		if err != nil {
+			return err
+		}
+		if ok {
+			bot.Logger().Warn("Legacy user already in state store, skipped", "user_id", u.ID,
+				"username", u.Username, "group", u.Group)
+			continue
+		}
+
+		err = state.Set(key, u)
+		if err != nil {
+			return err
+		}
+	}
+
+	if legacy.OwnerID == 0 {
+		return nil
+	}
+
+	var ownerID int
+	_, err = state.Get(stateKeyOwner, &ownerID)
+	if err != nil {
+		return err
+	}
+	if ownerID != 0 {
+		if ownerID != legacy.OwnerID {
+			bot.Logger().Warn("Legacy owner skipped, state store already has one", "user_id", legacy.OwnerID)
+		}
+		return nil
+	}
+
+	return state.Set(stateKeyOwner, legacy.OwnerID)
+}
+
+func (bot *Bot) storeUser(state *storage.Handle, u user) {
+	err := state.Set(stateKeyUserPrefix+strconv.Itoa(u.ID), u)
+	if err != nil {
+		bot.Logger().Error("Cannot store user", "user_id", u.ID, "err", err)
+	}
+}
+
+func (bot *Bot) storeOwner(state *storage.Handle, ownerID int) {
+	err := state.Set(stateKeyOwner, ownerID)
+	if err != nil {
+		bot.Logger().Error("Cannot store owner", "err", err)
+	}
+}
+
+// rende persistente un utente aggiunto o modificato
+func (bot *Bot) saveUser(u user) {
+	state, err := bot.stateStorage()
+	if err != nil {
+		bot.Logger().Error("Cannot open state store", "err", err)
+		return
+	}
+
+	bot.storeUser(state, u)
+}
+
+// rimuove un utente dallo store
+func (bot *Bot) removeUser(userID int) {
+	state, err := bot.stateStorage()
+	if err != nil {
+		bot.Logger().Error("Cannot open state store", "err", err)
+		return
+	}
+
+	err = state.Delete(stateKeyUserPrefix + strconv.Itoa(userID))
+	if err != nil {
+		bot.Logger().Error("Cannot remove user", "user_id", userID, "err", err)
+	}
+}
+
+// rende persistente l'owner
+func (bot *Bot) saveOwner(ownerID int) {
+	state, err := bot.stateStorage()
+	if err != nil {
+		bot.Logger().Error("Cannot open state store", "err", err)
+		return
+	}
+
+	bot.storeOwner(state, ownerID)
+}
+
+// migrazione della configurazione dalla versione 0 alla 1:
+// OwnerID e Users vengono spostati nello stato di runtime. L'importazione avviene
+// prima che il file migrato venga salvato: se fallisce il file resta invariato.
+func (bot *Bot) migrateConfigV0(data json.RawMessage) (json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	// i dati non validi non vengono importati nè rimossi dal file
+	legacy := legacyState{}
+	err = json.Unmarshal(data, &legacy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OwnerID or Users: %v", err)
+	}
+	if legacy.OwnerID != 0 || len(legacy.Users) > 0 {
+		err = bot.importLegacyState(legacy)
+		if err != nil {
+			return nil, fmt.Errorf("cannot import users into state store: %v", err)
+		}
+	}
+
+	delete(fields, "OwnerID")
+	delete(fields, "Users")
+
+	return json.Marshal(fields)
+}
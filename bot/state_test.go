@@ -0,0 +1,172 @@
+package bot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// configurazione precedente alla versione 1 dello schema
+const legacyConfigContent = `{
+	"Bot": {
+		"SecureToken": "test",
+		"OwnerID": 1,
+		"Users": [
+			{"ID": 1, "Username": "anna", "Group": "owner", "PrivateChatID": 1},
+			{"ID": 2, "Username": "mario", "Group": "admin"},
+			{"ID": 3, "Username": "luigi", "Group": ""}
+		]
+	}
+}`
+
+func newLegacyTestBot(t *testing.T) (*Bot, string) {
+	return newLegacyTestBotContent(t, legacyConfigContent)
+}
+
+func newLegacyTestBotContent(t *testing.T, content string) (*Bot, string) {
+	dir, err := ioutil.TempDir("", "bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	filename := filepath.Join(dir, "settings.json")
+	err = ioutil.WriteFile(filename, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bot := NewBot(filename, false, false)
+	t.Cleanup(func() {
+		if bot.store != nil {
+			bot.store.Close()
+		}
+	})
+
+	return bot, filename
+}
+
+func TestMigrateLegacyState(t *testing.T) {
+	bot, filename := newLegacyTestBot(t)
+	bot.StateFilename = "state.json"
+
+	err := bot.LoadConfig()
+	if err != nil {
+		t.Fatal("Error loading config:", err)
+	}
+
+	// gli utenti sono già nello store quando il file migrato viene salvato
+	content, _ := ioutil.ReadFile(filename)
+	if strings.Contains(string(content), "OwnerID") || strings.Contains(string(content), "mario") {
+		t.Error("Legacy fields not removed from config:", string(content))
+	}
+
+	err = bot.loadState()
+	if err != nil {
+		t.Fatal("Error loading state:", err)
+	}
+	if bot.ownerID != 1 {
+		t.Error("Unexpected owner:", bot.ownerID)
+	}
+	if len(bot.lookupUsers) != 3 {
+		t.Error("Unexpected users:", bot.lookupUsers)
+	}
+	if u, ok := bot.lookupUsers[1]; !ok || u.Group != groupOwner || u.PrivateChatID != 1 {
+		t.Error("Legacy owner not imported:", u)
+	}
+	if u, ok := bot.lookupUsers[2]; !ok || u.Username != "mario" || u.Group != groupAdmin {
+		t.Error("Legacy user not imported:", u)
+	}
+}
+
+func TestMigrateLegacyStateMerge(t *testing.T) {
+	bot, _ := newLegacyTestBot(t)
+	bot.StateFilename = "state.json"
+
+	// lo store contiene già un utente e l'owner: prevalgono su quelli del file
+	state, err := bot.stateStorage()
+	if err != nil {
+		t.Fatal("Error opening state:", err)
+	}
+	state.Set(stateKeyUserPrefix+"2", user{ID: 2, Username: "mario2"})
+	state.Set(stateKeyOwner, 2)
+
+	err = bot.LoadConfig()
+	if err != nil {
+		t.Fatal("Error loading config:", err)
+	}
+	err = bot.loadState()
+	if err != nil {
+		t.Fatal("Error loading state:", err)
+	}
+
+	if bot.ownerID != 2 {
+		t.Error("Stored owner overwritten:", bot.ownerID)
+	}
+	if len(bot.lookupUsers) != 3 {
+		t.Error("Legacy users not merged:", bot.lookupUsers)
+	}
+	if u := bot.lookupUsers[2]; u == nil || u.Username != "mario2" || u.Group != "" {
+		t.Error("Stored user overwritten:", u)
+	}
+}
+
+func TestMigrateLegacyStateInvalid(t *testing.T) {
+	content := `{"Bot": {"SecureToken": "test", "OwnerID": 1, "Users": [{"ID": 2}, {"ID": "x"}]}}`
+	bot, filename := newLegacyTestBotContent(t, content)
+	bot.StateFilename = "state.json"
+
+	if err := bot.LoadConfig(); err == nil {
+		t.Fatal("Invalid legacy users loaded")
+	}
+
+	// nulla viene importato e il file resta invariato
+	saved, _ := ioutil.ReadFile(filename)
+	if string(saved) != content {
+		t.Error("Config rewritten after invalid legacy users:", string(saved))
+	}
+
+	state, _ := bot.stateStorage()
+	if keys, _ := state.List(stateKeyUserPrefix); len(keys) != 0 {
+		t.Error("Invalid legacy users imported:", keys)
+	}
+}
+
+func TestMigrateLegacyStateFailure(t *testing.T) {
+	bot, filename := newLegacyTestBot(t)
+	// lo store non è apribile (è una directory)
+	bot.StateFilename = filepath.Dir(filename)
+
+	if err := bot.LoadConfig(); err == nil {
+		t.Fatal("Config loaded without importing users")
+	}
+
+	// il file originale resta invariato
+	content, _ := ioutil.ReadFile(filename)
+	if string(content) != legacyConfigContent {
+		t.Error("Config rewritten after failed import:", string(content))
+	}
+}
+
+func TestStorageReservedScope(t *testing.T) {
+	bot := newTestBot(t)
+
+	for _, scope := range []string{"Bot", "bot", "BOT"} {
+		if _, err := bot.Storage(scope); err != ErrReservedScope {
+			t.Error("Bot scope not reserved:", scope, err)
+		}
+	}
+
+	h, err := bot.Storage("myscope")
+	if err != nil {
+		t.Fatal("Error opening storage:", err)
+	}
+	h.Set(stateKeyOwner, 99)
+
+	state, _ := bot.stateStorage()
+	if ok, _ := state.Get(stateKeyOwner, new(int)); ok {
+		t.Error("Processor scope shares the bot state")
+	}
+}
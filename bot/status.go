@@ -0,0 +1,232 @@
+package bot
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"sort"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// stato di funzionamento, aggiornato dal ciclo di Do
+type runtimeStatus struct {
+	startTime  time.Time
+	running    bool
+	lastUpdate time.Time
+	rate       [60]rateBucket // update per secondo nell'ultimo minuto
+	updates    tgbotapi.UpdatesChannel
+
+	processorErrors map[string]ProcessorError
+}
+
+type rateBucket struct {
+	second int64
+	count  int
+}
+
+// Status - stato di funzionamento del bot (comando /status ed endpoint HTTP /status)
+type Status struct {
+	Version          string
+	Running          bool
+	StartTime        time.Time
+	Uptime           string
+	LastUpdate       *time.Time // nil se non è ancora arrivata nessuna update
+	UpdatesPerMinute int
+	Backlog          int // update ricevute in attesa di essere processate
+
+	Settings   SettingsStatus
+	Processors []ProcessorStatus
+}
+
+// SettingsStatus - esito dei salvataggi della configurazione
+type SettingsStatus struct {
+	LastSave  *time.Time
+	LastError string
+	Pending   bool
+}
+
+// ProcessorStatus - stato di un processore
+type ProcessorStatus struct {
+	Name      string
+	Version   string
+	LastError *ProcessorError
+}
+
+// ProcessorError - ultimo errore ritornato da un processore
+type ProcessorError struct {
+	Time  time.Time
+	Error string
+}
+
+// registra l'arrivo di un'update
+func (bot *Bot) recordUpdate() {
+	bot.recordUpdateAt(time.Now())
+}
+
+func (bot *Bot) recordUpdateAt(now time.Time) {
+	second := now.Unix()
+
+	bot.statusLock.Lock()
+	defer bot.statusLock.Unlock()
+
+	bot.status.lastUpdate = now
+
+	b := &bot.status.rate[second%int64(len(bot.status.rate))]
+	if b.second != second {
+		b.second = second
+		b.count = 0
+	}
+	b.count++
+}
+
+func (bot *Bot) recordProcessorError(name string, err error) {
+	e := ProcessorError{time.Now(), bot.redact(err.Error())}
+
+	bot.statusLock.Lock()
+	defer bot.statusLock.Unlock()
+
+	if bot.status.processorErrors == nil {
+		bot.status.processorErrors = make(map[string]ProcessorError)
+	}
+	bot.status.processorErrors[name] = e
+}
+
+// numero di update ricevute in attesa
+func (bot *Bot) backlog() int {
+	bot.statusLock.Lock()
+	defer bot.statusLock.Unlock()
+
+	if bot.status.updates == nil {
+		return 0
+	}
+	return len(bot.status.updates)
+}
+
+// Status - ritorna lo stato di funzionamento del bot
+func (bot *Bot) Status() Status {
+	return bot.statusAt(time.Now())
+}
+
+func (bot *Bot) statusAt(now time.Time) Status {
+	bot.statusLock.Lock()
+
+	status := Status{
+		Version:   version,
+		Running:   bot.status.running,
+		StartTime: bot.status.startTime,
+	}
+	if !bot.status.startTime.IsZero() {
+		status.Uptime = now.Sub(bot.status.startTime).Round(time.Second).String()
+	}
+	if !bot.status.lastUpdate.IsZero() {
+		lastUpdate := bot.status.lastUpdate
+		status.LastUpdate = &lastUpdate
+	}
+	for _, b := range bot.status.rate {
+		if now.Unix()-b.second < int64(len(bot.status.rate)) {
+			status.UpdatesPerMinute += b.count
+		}
+	}
+	if bot.status.updates != nil {
+		status.Backlog = len(bot.status.updates)
+	}
+
+	lastErrors := make(map[string]ProcessorError)
+	for name, e := range bot.status.processorErrors {
+		lastErrors[name] = e
+	}
+
+	bot.statusLock.Unlock()
+
+	saveStatus := bot.configCtrl.SaveStatus()
+	status.Settings.Pending = saveStatus.Pending
+	if !saveStatus.LastSave.IsZero() {
+		status.Settings.LastSave = &saveStatus.LastSave
+	}
+	if saveStatus.LastError != nil {
+		status.Settings.LastError = saveStatus.LastError.Error()
+	}
+
+	for _, e := range bot.processors {
+		ps := ProcessorStatus{Name: e.name, Version: e.processor.Version()}
+		if e, ok := lastErrors[ps.Name]; ok {
+			ps.LastError = &e
+		}
+		status.Processors = append(status.Processors, ps)
+	}
+	sort.SliceStable(status.Processors, func(i, j int) bool {
+		return status.Processors[i].Name < status.Processors[j].Name
+	})
+
+	return status
+}
+
+// testo del comando /status
+func (status Status) html(tr translator) string {
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return tr("bot.status.never")
+		}
+		return t.Format("2006-01-02 15:04:05")
+	}
+
+	text := tr("bot.status.version", status.Version) +
+		tr("bot.status.uptime", status.Uptime) +
+		tr("bot.status.lastUpdate", formatTime(status.LastUpdate)) +
+		tr("bot.status.rate", status.UpdatesPerMinute) +
+		tr("bot.status.backlog", status.Backlog)
+
+	text += "\n<b>" + tr("bot.status.settings") + "</b>\n" + tr("bot.status.lastSave", formatTime(status.Settings.LastSave))
+	if status.Settings.Pending {
+		text += tr("bot.status.savePending")
+	}
+	if status.Settings.LastError != "" {
+		text += "\n" + tr("bot.status.saveError", html.EscapeString(status.Settings.LastError))
+	}
+
+	text += "\n\n<b>" + tr("bot.status.processors") + "</b>\n"
+	for _, p := range status.Processors {
+		text += html.EscapeString(p.Name) + " <code>" + html.EscapeString(p.Version) + "</code>"
+		if p.LastError != nil {
+			text += "\n  " + tr("bot.status.lastError", p.LastError.Time.Format("2006-01-02 15:04:05"), html.EscapeString(p.LastError.Error))
+		}
+		text += "\n"
+	}
+
+	return text
+}
+
+func (bot *Bot) processStatusCommand(handler MessageHandler) {
+	if handler.Group != groupOwner {
+		bot.logNoPermission(handler, commandStatus)
+		return
+	}
+
+	opt := bot.NewMessageResponseOpt()
+	bot.SendMessageResponse(handler, bot.Status().html(bot.translator(handler.Language)), opt)
+}
+
+// /healthz: 200 se il bot sta ricevendo le update, altrimenti 503
+func (bot *Bot) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	bot.statusLock.Lock()
+	running := bot.status.running
+	bot.statusLock.Unlock()
+
+	if !running {
+		http.Error(w, "not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Write([]byte("ok\n"))
+}
+
+// /status: stato in JSON
+func (bot *Bot) handleStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(bot.Status())
+}
@@ -0,0 +1,229 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// API di Telegram simulate: getMe ritorna il bot, getUpdates nessuna update
+// e tutti gli altri metodi rispondono con successo
+type fakeTelegram struct{}
+
+func (fakeTelegram) RoundTrip(req *http.Request) (*http.Response, error) {
+	result := "true"
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/getMe"):
+		result = `{"id": 1, "is_bot": true, "first_name": "Test", "username": "testbot"}`
+	case strings.HasSuffix(req.URL.Path, "/getUpdates"):
+		// long polling abbreviato
+		time.Sleep(10 * time.Millisecond)
+		result = "[]"
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(`{"ok": true, "result": ` + result + `}`)),
+		Request:    req,
+	}, nil
+}
+
+// esecuzione di Do in background sulle API simulate
+type testRun struct {
+	bot  *Bot
+	done chan error
+	once sync.Once
+	err  error
+}
+
+func runTestBot(t *testing.T, bot *Bot) *testRun {
+	bot.apiClient = &http.Client{Transport: fakeTelegram{}}
+
+	run := &testRun{bot: bot, done: make(chan error, 1)}
+	go func() { run.done <- bot.Do() }()
+
+	t.Cleanup(func() { run.stop() })
+
+	return run
+}
+
+// ferma il bot e ritorna l'esito di Do
+func (run *testRun) stop() error {
+	run.once.Do(func() {
+		run.bot.Stop()
+		run.err = <-run.done
+	})
+	return run.err
+}
+
+// attende che il bot riceva le update
+func (run *testRun) waitRunning(t *testing.T) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !run.bot.Status().Running {
+		select {
+		case err := <-run.done:
+			t.Fatal("Do returned before running:", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Bot not running")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStatusRate(t *testing.T) {
+	bot := newTestBot(t)
+	start := time.Unix(1000, 0)
+
+	bot.recordUpdateAt(start)
+	bot.recordUpdateAt(start.Add(500 * time.Millisecond))
+	bot.recordUpdateAt(start.Add(30 * time.Second))
+
+	tests := []struct {
+		after time.Duration
+		want  int
+	}{
+		{30 * time.Second, 3},
+		{59 * time.Second, 3},
+		// le update del primo secondo escono dalla finestra
+		{60 * time.Second, 1},
+		{90 * time.Second, 0},
+		{time.Hour, 0},
+	}
+
+	for i, test := range tests {
+		if got := bot.statusAt(start.Add(test.after)).UpdatesPerMinute; got != test.want {
+			t.Errorf("%d: UpdatesPerMinute after %v = %d, want %d", i, test.after, got, test.want)
+		}
+	}
+
+	// lo stesso bucket un minuto dopo riparte da zero
+	bot.recordUpdateAt(start.Add(60 * time.Second))
+	status := bot.statusAt(start.Add(60 * time.Second))
+	if status.UpdatesPerMinute != 2 {
+		t.Error("Reused bucket not reset:", status.UpdatesPerMinute)
+	}
+	if status.LastUpdate == nil || !status.LastUpdate.Equal(start.Add(60*time.Second)) {
+		t.Error("Unexpected last update:", status.LastUpdate)
+	}
+}
+
+func TestStatusBacklog(t *testing.T) {
+	bot := newTestBot(t)
+
+	if bot.Status().Backlog != 0 || bot.backlog() != 0 {
+		t.Error("Backlog without updates channel")
+	}
+
+	updates := make(chan tgbotapi.Update, 10)
+	updates <- tgbotapi.Update{UpdateID: 1}
+	updates <- tgbotapi.Update{UpdateID: 2}
+	bot.status.updates = updates
+
+	if got := bot.Status().Backlog; got != 2 {
+		t.Error("Unexpected backlog:", got)
+	}
+
+	<-updates
+	if got := bot.backlog(); got != 1 {
+		t.Error("Unexpected backlog after receive:", got)
+	}
+}
+
+func TestHandleHealthz(t *testing.T) {
+	bot := newTestBot(t)
+	if err := bot.LoadConfig(); err != nil {
+		t.Fatal("Error loading config:", err)
+	}
+
+	healthz := func() int {
+		w := httptest.NewRecorder()
+		bot.handleHealthz(w, httptest.NewRequest("GET", "/healthz", nil))
+		return w.Code
+	}
+
+	if code := healthz(); code != http.StatusServiceUnavailable {
+		t.Error("Unexpected status before Do:", code)
+	}
+
+	run := runTestBot(t, bot)
+	run.waitRunning(t)
+
+	if code := healthz(); code != http.StatusOK {
+		t.Error("Unexpected status while running:", code)
+	}
+
+	if err := run.stop(); err != nil {
+		t.Fatal("Do error:", err)
+	}
+
+	if code := healthz(); code != http.StatusServiceUnavailable {
+		t.Error("Unexpected status after Stop:", code)
+	}
+}
+
+func TestHandleStatus(t *testing.T) {
+	bot := newTestBot(t)
+	bot.RegisterProcessor("MyProcessor", &StubProcessor{}, nil)
+	if err := bot.LoadConfig(); err != nil {
+		t.Fatal("Error loading config:", err)
+	}
+	bot.recordProcessorError("MyProcessor", errors.New("test error"))
+
+	w := httptest.NewRecorder()
+	bot.handleStatus(w, httptest.NewRequest("GET", "/status", nil))
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Error("Unexpected content type:", ct)
+	}
+
+	var status map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal("Invalid JSON:", err, w.Body.String())
+	}
+
+	for _, key := range []string{"Version", "Running", "StartTime", "Uptime", "LastUpdate",
+		"UpdatesPerMinute", "Backlog", "Settings", "Processors"} {
+		if _, ok := status[key]; !ok {
+			t.Error("Missing key:", key)
+		}
+	}
+	if status["Running"] != false || status["LastUpdate"] != nil || status["Backlog"] != float64(0) {
+		t.Error("Unexpected status:", status)
+	}
+
+	settings, _ := status["Settings"].(map[string]interface{})
+	for _, key := range []string{"LastSave", "LastError", "Pending"} {
+		if _, ok := settings[key]; !ok {
+			t.Error("Missing settings key:", key)
+		}
+	}
+
+	processors, _ := status["Processors"].([]interface{})
+	var found bool
+	for _, p := range processors {
+		p, _ := p.(map[string]interface{})
+		if p["Name"] != "MyProcessor" {
+			continue
+		}
+		found = true
+
+		lastError, _ := p["LastError"].(map[string]interface{})
+		if _, ok := p["Version"]; !ok || lastError["Error"] != "test error" || lastError["Time"] == nil {
+			t.Error("Unexpected processor status:", p)
+		}
+	}
+	if !found {
+		t.Error("Processor not reported:", processors)
+	}
+}
@@ -0,0 +1,226 @@
+package bot
+
+// messaggi predefiniti del bot, per lingua (vedi RegisterTranslations)
+var builtinTranslations = map[string]map[string]string{
+	"en": {
+		"bot.help.owner":     "/owner  Reset the owner\n",
+		"bot.help.user":      "/user  Users commands\n",
+		"bot.help.language":  "/language [code|default] [chat]  Show or change the language of the replies\n",
+		"bot.help.silence":   "/silence [off]  Stop (or restart) messages autoparsing\n",
+		"bot.help.purge":     "/purge [n]  Delete the last n bot replies (or the replies to the quoted message) (owner and admins)\n",
+		"bot.help.reload":    "/reload  Reload the settings file (owner only)\n",
+		"bot.help.config":    "/config show [scope]  Show the effective configuration (owner only)\n/config get|set scope.Field [value]  Read or change a setting (owner and admins)\n",
+		"bot.help.processor": "/processor list|order  Show the processors and their dispatch order (owner and admins)\n/processor enable|disable name [chat|here]  Toggle a processor globally or in a chat (owner and admins)\n",
+		"bot.help.status":    "/status  Show uptime, activity and errors (owner only)\n",
+		"bot.help.ping":      "/ping  Test the bot\n",
+
+		"bot.menu.help":      "Show the available commands",
+		"bot.menu.ping":      "Test the bot",
+		"bot.menu.language":  "Change the language of the replies",
+		"bot.menu.silence":   "Stop (or restart) messages autoparsing",
+		"bot.menu.purge":     "Delete the last bot replies",
+		"bot.menu.user":      "Users commands",
+		"bot.menu.config":    "Read or change the settings",
+		"bot.menu.processor": "Show and toggle the processors",
+		"bot.menu.reload":    "Reload the settings file",
+		"bot.menu.status":    "Show uptime, activity and errors",
+
+		"bot.owner.reset": "You are the owner of this bot, now",
+
+		"bot.silence.off": "Silence mode off",
+		"bot.silence.on":  "Silenced for <code>%v</code> minutes",
+
+		"bot.purge.invalid": "Invalid number of messages",
+		"bot.purge.deleted": "Deleted <code>%v</code> messages",
+
+		"bot.reload.failed":    "Reload failed: <code>%v</code>",
+		"bot.reload.unchanged": "Settings reloaded, nothing changed",
+		"bot.reload.changed":   "Settings reloaded, changed: <code>%v</code>",
+
+		"bot.ping.uptime": "Uptime",
+
+		"bot.language.current":   "Language: <code>%v</code>\nAvailable: <code>%v</code>",
+		"bot.language.unknown":   "Unknown language <code>%v</code>\nAvailable: <code>%v</code>",
+		"bot.language.set":       "Language set to <code>%v</code>",
+		"bot.language.setChat":   "Chat language set to <code>%v</code>",
+		"bot.language.reset":     "Language reset to the default",
+		"bot.language.resetChat": "Chat language reset to the default",
+
+		"bot.user.help": "<code>user</code> command parameters:\n" +
+			"  <code>list</code>  Show users list\n" +
+			"  <code>add {id}</code>  Add user to whitelist\n" +
+			"  <code>remove {id|username}</code>  Remove user from whitelist\n" +
+			"  <code>group [none|admin] {id|username}</code>  Change user's group\n" +
+			"  <code>email {address} {id|username}</code>  Change user's e-mail (\"none\" = unset)\n" +
+			"\nHint: <code>{id}</code> could be avoided by replying to a user's message",
+		"bot.user.invalid":         "Invalid UserID",
+		"bot.user.alreadyYou":      "Your are already in whitelist",
+		"bot.user.added":           "User <code>%v %v</code> added to whitelist",
+		"bot.user.alreadyAdded":    "User <code>%v</code> already in whitelist",
+		"bot.user.cannotRemoveOwn": "Cannot remove my owner",
+		"bot.user.removed":         "User <code>%v</code> deleted from whitelist",
+		"bot.user.notInWhitelist":  "User <code>%v</code> not in whitelist",
+		"bot.user.notExists":       "User <code>%v</code> not exists",
+		"bot.user.groupSet":        "User <code>%v %v</code> set to <code>%v</code>",
+		"bot.user.emailSet":        "User <code>%v %v</code> email: <code>%v</code>",
+		"bot.user.emailNone":       "(none)",
+		"bot.user.list":            "Users list:\n\n",
+		"bot.user.pending":         "(pending)",
+		"bot.user.active":          "(active)",
+
+		"bot.unknownSubcommand": "Unknown subcommand <code>%v</code>",
+
+		"bot.processor.help": "<code>/processor list</code>  Show the processors\n" +
+			"<code>/processor order</code>  Show the dispatch order\n" +
+			"<code>/processor enable|disable name</code>  Toggle a processor in all chats\n" +
+			"<code>/processor enable|disable name chat|here</code>  Toggle a processor in a chat",
+		"bot.processor.updates":         "Updates",
+		"bot.processor.commands":        "Commands and messages",
+		"bot.processor.observers":       "Observers",
+		"bot.processor.observersNote":   "(see everything first, never consume)",
+		"bot.processor.isDisabled":      "disabled",
+		"bot.processor.isDisabledHere":  "disabled in this chat",
+		"bot.processor.isDisabledChats": "disabled in chats %v",
+		"bot.processor.unknown":         "Unknown processor <code>%v</code>",
+		"bot.processor.botNotDisabled":  "The bot processor cannot be disabled",
+		"bot.processor.invalidChat":     "Invalid chat ID <code>%v</code>",
+		"bot.processor.enabled":         "%v enabled",
+		"bot.processor.disabled":        "%v disabled",
+		"bot.processor.enabledChat":     "%v enabled in chat <code>%v</code>",
+		"bot.processor.disabledChat":    "%v disabled in chat <code>%v</code>",
+		"bot.processor.stillDisabled":   " (still disabled in all chats)",
+
+		"bot.config.help": "<code>/config show [scope]</code>\n" +
+			"<code>/config get scope.Field</code>\n" +
+			"<code>/config set scope.Field value</code>",
+		"bot.config.cannotShow":   "Cannot show settings: <code>%v</code>",
+		"bot.config.expectedPath": "Expected <code>scope.Field</code>",
+		"bot.config.secret":       "Secret fields can only be changed in the settings file",
+		"bot.config.cannotSet":    "Cannot set value: <code>%v</code>",
+		"bot.config.overridden":   "The field is overridden by an environment variable or flag: the change will be lost on restart",
+
+		"bot.status.version":     "Version <code>%v</code>\n",
+		"bot.status.uptime":      "Uptime <code>%v</code>\n",
+		"bot.status.lastUpdate":  "Last update <code>%v</code>\n",
+		"bot.status.rate":        "Updates/min <code>%v</code>\n",
+		"bot.status.backlog":     "Backlog <code>%v</code>\n",
+		"bot.status.settings":    "Settings",
+		"bot.status.lastSave":    "Last save <code>%v</code>",
+		"bot.status.savePending": " (save pending)",
+		"bot.status.saveError":   "Save error <code>%v</code>",
+		"bot.status.processors":  "Processors",
+		"bot.status.lastError":   "last error %v <code>%v</code>",
+		"bot.status.never":       "never",
+	},
+
+	"it": {
+		"bot.help.owner":     "/owner  Reimposta il proprietario\n",
+		"bot.help.user":      "/user  Comandi degli utenti\n",
+		"bot.help.language":  "/language [codice|default] [chat]  Mostra o cambia la lingua delle risposte\n",
+		"bot.help.silence":   "/silence [off]  Sospende (o riprende) l'analisi dei messaggi\n",
+		"bot.help.purge":     "/purge [n]  Elimina le ultime n risposte del bot (o le risposte al messaggio citato) (owner e admin)\n",
+		"bot.help.reload":    "/reload  Ricarica il file di configurazione (solo owner)\n",
+		"bot.help.config":    "/config show [scope]  Mostra la configurazione effettiva (solo owner)\n/config get|set scope.Campo [valore]  Legge o modifica un'impostazione (owner e admin)\n",
+		"bot.help.processor": "/processor list|order  Mostra i processori e il loro ordine (owner e admin)\n/processor enable|disable nome [chat|here]  Attiva o disattiva un processore ovunque o in una chat (owner e admin)\n",
+		"bot.help.status":    "/status  Mostra uptime, attività ed errori (solo owner)\n",
+		"bot.help.ping":      "/ping  Verifica il bot\n",
+
+		"bot.menu.help":      "Mostra i comandi disponibili",
+		"bot.menu.ping":      "Verifica il bot",
+		"bot.menu.language":  "Cambia la lingua delle risposte",
+		"bot.menu.silence":   "Sospende (o riprende) l'analisi dei messaggi",
+		"bot.menu.purge":     "Elimina le ultime risposte del bot",
+		"bot.menu.user":      "Comandi degli utenti",
+		"bot.menu.config":    "Legge o modifica le impostazioni",
+		"bot.menu.processor": "Mostra e attiva i processori",
+		"bot.menu.reload":    "Ricarica il file di configurazione",
+		"bot.menu.status":    "Mostra uptime, attività ed errori",
+
+		"bot.owner.reset": "Ora sei il proprietario di questo bot",
+
+		"bot.silence.off": "Silenzio disattivato",
+		"bot.silence.on":  "Silenzio per <code>%v</code> minuti",
+
+		"bot.purge.invalid": "Numero di messaggi non valido",
+		"bot.purge.deleted": "Eliminati <code>%v</code> messaggi",
+
+		"bot.reload.failed":    "Ricaricamento fallito: <code>%v</code>",
+		"bot.reload.unchanged": "Configurazione ricaricata, nessuna modifica",
+		"bot.reload.changed":   "Configurazione ricaricata, modificati: <code>%v</code>",
+
+		"bot.ping.uptime": "Attivo da",
+
+		"bot.language.current":   "Lingua: <code>%v</code>\nDisponibili: <code>%v</code>",
+		"bot.language.unknown":   "Lingua <code>%v</code> sconosciuta\nDisponibili: <code>%v</code>",
+		"bot.language.set":       "Lingua impostata: <code>%v</code>",
+		"bot.language.setChat":   "Lingua della chat impostata: <code>%v</code>",
+		"bot.language.reset":     "Lingua riportata a quella di default",
+		"bot.language.resetChat": "Lingua della chat riportata a quella di default",
+
+		"bot.user.help": "Parametri del comando <code>user</code>:\n" +
+			"  <code>list</code>  Mostra l'elenco degli utenti\n" +
+			"  <code>add {id}</code>  Aggiunge l'utente alla whitelist\n" +
+			"  <code>remove {id|username}</code>  Rimuove l'utente dalla whitelist\n" +
+			"  <code>group [none|admin] {id|username}</code>  Cambia il gruppo dell'utente\n" +
+			"  <code>email {indirizzo} {id|username}</code>  Cambia l'e-mail dell'utente (\"none\" = nessuna)\n" +
+			"\nSuggerimento: <code>{id}</code> può essere omesso rispondendo a un messaggio dell'utente",
+		"bot.user.invalid":         "UserID non valido",
+		"bot.user.alreadyYou":      "Sei già nella whitelist",
+		"bot.user.added":           "Utente <code>%v %v</code> aggiunto alla whitelist",
+		"bot.user.alreadyAdded":    "L'utente <code>%v</code> è già nella whitelist",
+		"bot.user.cannotRemoveOwn": "Non posso rimuovere il mio proprietario",
+		"bot.user.removed":         "Utente <code>%v</code> rimosso dalla whitelist",
+		"bot.user.notInWhitelist":  "L'utente <code>%v</code> non è nella whitelist",
+		"bot.user.notExists":       "L'utente <code>%v</code> non esiste",
+		"bot.user.groupSet":        "Utente <code>%v %v</code> impostato a <code>%v</code>",
+		"bot.user.emailSet":        "E-mail dell'utente <code>%v %v</code>: <code>%v</code>",
+		"bot.user.emailNone":       "(nessuna)",
+		"bot.user.list":            "Elenco utenti:\n\n",
+		"bot.user.pending":         "(in attesa)",
+		"bot.user.active":          "(attivo)",
+
+		"bot.unknownSubcommand": "Sottocomando <code>%v</code> sconosciuto",
+
+		"bot.processor.help": "<code>/processor list</code>  Mostra i processori\n" +
+			"<code>/processor order</code>  Mostra l'ordine di dispatch\n" +
+			"<code>/processor enable|disable nome</code>  Attiva o disattiva un processore in tutte le chat\n" +
+			"<code>/processor enable|disable nome chat|here</code>  Attiva o disattiva un processore in una chat",
+		"bot.processor.updates":         "Update",
+		"bot.processor.commands":        "Comandi e messaggi",
+		"bot.processor.observers":       "Osservatori",
+		"bot.processor.observersNote":   "(vedono tutto per primi, senza mai consumare)",
+		"bot.processor.isDisabled":      "disattivato",
+		"bot.processor.isDisabledHere":  "disattivato in questa chat",
+		"bot.processor.isDisabledChats": "disattivato nelle chat %v",
+		"bot.processor.unknown":         "Processore <code>%v</code> sconosciuto",
+		"bot.processor.botNotDisabled":  "Il processore del bot non può essere disattivato",
+		"bot.processor.invalidChat":     "ID della chat <code>%v</code> non valido",
+		"bot.processor.enabled":         "%v attivato",
+		"bot.processor.disabled":        "%v disattivato",
+		"bot.processor.enabledChat":     "%v attivato nella chat <code>%v</code>",
+		"bot.processor.disabledChat":    "%v disattivato nella chat <code>%v</code>",
+		"bot.processor.stillDisabled":   " (ancora disattivato in tutte le chat)",
+
+		"bot.config.help": "<code>/config show [scope]</code>\n" +
+			"<code>/config get scope.Campo</code>\n" +
+			"<code>/config set scope.Campo valore</code>",
+		"bot.config.cannotShow":   "Impossibile mostrare la configurazione: <code>%v</code>",
+		"bot.config.expectedPath": "Atteso <code>scope.Campo</code>",
+		"bot.config.secret":       "I campi riservati possono essere modificati soltanto nel file di configurazione",
+		"bot.config.cannotSet":    "Impossibile impostare il valore: <code>%v</code>",
+		"bot.config.overridden":   "Il campo è sovrascritto da una variabile d'ambiente o da un flag: la modifica andrà persa al riavvio",
+
+		"bot.status.version":     "Versione <code>%v</code>\n",
+		"bot.status.uptime":      "Attivo da <code>%v</code>\n",
+		"bot.status.lastUpdate":  "Ultima update <code>%v</code>\n",
+		"bot.status.rate":        "Update/min <code>%v</code>\n",
+		"bot.status.backlog":     "In coda <code>%v</code>\n",
+		"bot.status.settings":    "Configurazione",
+		"bot.status.lastSave":    "Ultimo salvataggio <code>%v</code>",
+		"bot.status.savePending": " (salvataggio in attesa)",
+		"bot.status.saveError":   "Errore di salvataggio <code>%v</code>",
+		"bot.status.processors":  "Processori",
+		"bot.status.lastError":   "ultimo errore %v <code>%v</code>",
+		"bot.status.never":       "mai",
+	},
+}
@@ -0,0 +1,31 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuiltinTranslations(t *testing.T) {
+	en := builtinTranslations["en"]
+
+	for lang, messages := range builtinTranslations {
+		for key, message := range en {
+			translated, ok := messages[key]
+			if !ok {
+				t.Errorf("%s: missing key %q", lang, key)
+				continue
+			}
+
+			// stessi argomenti di fmt.Sprintf in tutte le lingue
+			if strings.Count(translated, "%") != strings.Count(message, "%") {
+				t.Errorf("%s: key %q has different format verbs", lang, key)
+			}
+		}
+
+		for key := range messages {
+			if _, ok := en[key]; !ok {
+				t.Errorf("%s: unknown key %q", lang, key)
+			}
+		}
+	}
+}
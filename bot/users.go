@@ -2,8 +2,9 @@ package bot
 
 import (
 	"fmt"
-	"log"
+	"html"
 	"strconv"
+	"strings"
 )
 
 type userGroup string
@@ -24,10 +25,7 @@ type user struct {
 type usersLookupMap map[int]*user
 
 func (bot *Bot) GetUserEmail(userID int) (email string, ok bool) {
-	bot.configLock.Lock()
-	defer bot.configLock.Unlock()
-
-	u, ok := bot.lookupUsers[userID]
+	u, ok := bot.getUserByID(userID)
 	if !ok {
 		return "", false
 	}
@@ -35,71 +33,77 @@ func (bot *Bot) GetUserEmail(userID int) (email string, ok bool) {
 	return u.Email, true
 }
 
-func (bot *Bot) getUserByID(ID int) (u *user, ok bool) {
-	u, ok = bot.lookupUsers[ID]
+// ritorna una copia dei dati dell'utente
+func (bot *Bot) getUserByID(ID int) (u user, ok bool) {
+	bot.stateLock.RLock()
+	defer bot.stateLock.RUnlock()
+
+	pu, ok := bot.lookupUsers[ID]
+	if ok {
+		u = *pu
+	}
+
 	return
 }
 
-func (bot *Bot) getUserByUsername(username string) (u *user, ok bool) {
-	for _, u := range bot.lookupUsers {
-		if u.Username == username {
-			return u, true
+// ritorna una copia dei dati dell'utente
+func (bot *Bot) getUserByUsername(username string) (u user, ok bool) {
+	bot.stateLock.RLock()
+	defer bot.stateLock.RUnlock()
+
+	for _, pu := range bot.lookupUsers {
+		if pu.Username != "" && strings.EqualFold(pu.Username, username) {
+			return *pu, true
 		}
 	}
 
-	return nil, false
+	return
+}
+
+// ritorna l'ID dell'owner del bot
+func (bot *Bot) getOwnerID() int {
+	bot.stateLock.RLock()
+	defer bot.stateLock.RUnlock()
+
+	return bot.ownerID
 }
 
 func (bot *Bot) addUser(u user, failIfExists bool) bool {
-	bot.configLock.Lock()
-	defer bot.configLock.Unlock()
+	bot.stateLock.Lock()
+	defer bot.stateLock.Unlock()
 
-	_, ok := bot.lookupUsers[u.ID]
-	if ok {
-		if failIfExists {
-			return false
-		}
-		*bot.lookupUsers[u.ID] = u
-	} else {
-		bot.config.Users = append(bot.config.Users, u)
-		bot.lookupUsers[u.ID] = &bot.config.Users[len(bot.config.Users)-1]
+	if _, exists := bot.lookupUsers[u.ID]; exists && failIfExists {
+		return false
 	}
 
-	bot.SaveConfig()
+	nu := u
+	bot.lookupUsers[u.ID] = &nu
+
+	bot.saveUser(u)
 	return true
 }
 
 func (bot *Bot) deleteUser(userID int) bool {
-	_, ok := bot.lookupUsers[userID]
-	if !ok {
+	bot.stateLock.Lock()
+	defer bot.stateLock.Unlock()
+
+	if _, ok := bot.lookupUsers[userID]; !ok {
 		return false
 	}
 
-	bot.configLock.Lock()
-	defer bot.configLock.Unlock()
+	delete(bot.lookupUsers, userID)
 
-	for i, u := range bot.config.Users {
-		if u.ID == userID {
-			bot.config.Users[i] = bot.config.Users[len(bot.config.Users)-1]
-			bot.config.Users = bot.config.Users[:len(bot.config.Users)-1]
-			bot.initUsers()
-			break
-		}
-	}
-
-	bot.SaveConfig()
+	bot.removeUser(userID)
 	return true
 }
 
 func (bot *Bot) resetOwner(newOwnerID int, OwnerUsername string, privateChatID int64) {
-	bot.configLock.Lock()
-
-	log.Println("Reset owner to", newOwnerID)
+	bot.Logger().Warn("Reset owner", "user_id", newOwnerID)
 
-	bot.config.OwnerID = newOwnerID
-	bot.configLock.Unlock()
-
-	bot.SaveConfig()
+	bot.stateLock.Lock()
+	bot.ownerID = newOwnerID
+	bot.saveOwner(newOwnerID)
+	bot.stateLock.Unlock()
 
 	u := user{
 		ID:            newOwnerID,
@@ -109,76 +113,95 @@ func (bot *Bot) resetOwner(newOwnerID int, OwnerUsername string, privateChatID i
 	}
 
 	bot.addUser(u, false)
+	bot.refreshCommands()
 }
 
-func (bot *Bot) updateUserData(u *user, newu user) {
-	bot.configLock.Lock()
-	defer bot.configLock.Unlock()
-
-	*u = newu
+// modifica i dati di un utente esistente; ritorna false se l'utente non esiste
+func (bot *Bot) updateUser(userID int, modify func(u *user)) bool {
+	bot.stateLock.Lock()
+	defer bot.stateLock.Unlock()
 
-	if bot.Debug {
-		log.Println("Update user data", *u)
+	u, ok := bot.lookupUsers[userID]
+	if !ok {
+		return false
 	}
 
-	bot.SaveConfig()
-}
+	modify(u)
 
-func (bot *Bot) updateUsername(u *user, username string) {
-	nu := *u
-	nu.Username = username
+	bot.Logger().Debug("Update user data", "user_id", u.ID)
 
-	bot.updateUserData(u, nu)
+	bot.saveUser(*u)
+	return true
 }
 
-func (bot *Bot) updateUserPrivateChatID(u *user, privateChatID int64) {
-	nu := *u
-	nu.PrivateChatID = privateChatID
-
-	bot.updateUserData(u, nu)
+func (bot *Bot) updateUsername(userID int, username string) {
+	bot.updateUser(userID, func(u *user) {
+		u.Username = username
+	})
 }
 
-func (bot *Bot) initUsers() {
-	// make lookup
-	bot.lookupUsers = make(map[int]*user)
+func (bot *Bot) updateUserPrivateChatID(userID int, privateChatID int64) {
+	bot.updateUser(userID, func(u *user) {
+		u.PrivateChatID = privateChatID
+	})
+}
 
-	verboseIDs := make([]int, 0)
+// ritorna una copia di tutti gli utenti
+func (bot *Bot) listUsers() []user {
+	bot.stateLock.RLock()
+	defer bot.stateLock.RUnlock()
 
-	for i, user := range bot.config.Users {
-		bot.lookupUsers[user.ID] = &bot.config.Users[i]
-		verboseIDs = append(verboseIDs, user.ID)
+	users := make([]user, 0, len(bot.lookupUsers))
+	for _, u := range bot.lookupUsers {
+		users = append(users, *u)
 	}
 
-	if bot.Verbose {
-		log.Println("Users IDs", verboseIDs)
+	return users
+}
+
+// logga gli utenti autorizzati
+func (bot *Bot) logUsers() {
+	ids := make([]int, 0)
+
+	for _, u := range bot.listUsers() {
+		ids = append(ids, u.ID)
 	}
+
+	bot.Logger().Info("Authorized users", "user_ids", fmt.Sprint(ids))
 }
 
-// ParseUserID ritorna l'ID utente specificato dalla stringa in input.
+// ParseUserID ritorna l'ID utente specificato dalla stringa in input, 0 se non valido.
 // Se numerico lo ritorna tale e quale (mustExists verifica che l'utente esista nel DB interno).
-// Se "username" o "@username" cerca l'utente corrispondente nel DB interno.
+// Se "username" o "@username" cerca l'utente corrispondente nel DB interno e, se
+// mustExists è false, tra gli utenti visti nelle chat. Vedi anche ParseUserRef.
 func (bot *Bot) ParseUserID(s string, mustExists bool) int {
-	userID, err := strconv.Atoi(s)
+	if s == "" {
+		return 0
+	}
 
+	userID, err := strconv.Atoi(s)
 	if err != nil {
-		userID = 0
+		username := strings.TrimPrefix(s, "@")
 
-		if s[0] == '@' {
-			s = s[1:]
+		if u, ok := bot.getUserByUsername(username); ok {
+			return u.ID
 		}
-
-		u, ok := bot.getUserByUsername(s)
-		if ok {
-			userID = u.ID
+		if !mustExists {
+			if ref, ok := bot.seenUserByUsername(username); ok {
+				return ref.ID
+			}
 		}
 
-		return userID
+		return 0
+	}
+
+	if userID <= 0 {
+		return 0
 	}
 
 	if mustExists {
-		u, ok := bot.getUserByID(userID)
-		if ok {
-			userID = u.ID
+		if _, ok := bot.getUserByID(userID); !ok {
+			return 0
 		}
 	}
 
@@ -187,56 +210,34 @@ func (bot *Bot) ParseUserID(s string, mustExists bool) int {
 
 func (bot *Bot) processUserCommand(handler MessageHandler, params []string) error {
 	if handler.Group != groupOwner && handler.Group != groupAdmin {
-		if bot.Verbose {
-			log.Println("No permission for command")
-		}
+		bot.logNoPermission(handler, commandUser)
 		return nil
 	}
 
 	showHelp := func() {
-		help :=
-			"<code>user</code> command parameters:\n" +
-				"  <code>list</code>  Show users list\n" +
-				"  <code>add {id}</code>  Add user to whitelist\n" +
-				"  <code>remove {id|username}</code>  Remove user from whitelist\n" +
-				"  <code>group [none|admin] {id|username}</code>  Change user's group\n" +
-				"  <code>email {address} {id|username}</code>  Change user's e-mail (\"none\" = unset)\n" +
-				"\nHint: <code>{id}</code> could be avoided by replying to a user's message"
+		help := bot.T(handler.Language, "bot.user.help")
 
 		opt := bot.NewMessageResponseOpt()
 		bot.SendMessageResponseToPrivate(handler, help, opt)
 	}
 
+	// utente indicato dal parametro (ID, @username, menzione) o dalla risposta
 	parseUser := func(paramIdx int) (int, string, string) {
-		var userID int
-		var username string
-		var errorStr string
-
-		if len(params) > paramIdx {
-			userID = bot.ParseUserID(params[paramIdx], false)
-			if userID == 0 {
-				errorStr = "Invalid UserID"
-			}
-
-		} else {
-
-			switch handler.ReplyUserID {
-			case 0:
-				showHelp()
-
-			default:
-				userID = int(handler.ReplyUserID)
-				username = handler.ReplyUsername
-			}
+		if len(params) <= paramIdx && handler.ReplyUserID == 0 {
+			showHelp()
+			return 0, "", ""
+		}
 
+		ref, ok := bot.UserRefArg(handler, params, paramIdx)
+		if !ok {
+			return 0, "", bot.T(handler.Language, "bot.user.invalid")
 		}
 
-		if userID == bot.Tgbot.Self.ID {
-			userID = 0
-			errorStr = "lol"
+		if ref.ID == bot.Tgbot.Self.ID {
+			return 0, "", "lol"
 		}
 
-		return userID, username, errorStr
+		return ref.ID, ref.Username, ""
 	}
 
 	if len(params) < 1 {
@@ -254,7 +255,7 @@ func (bot *Bot) processUserCommand(handler MessageHandler, params []string) erro
 
 		if userID > 0 {
 			if userID == handler.UserID {
-				response = "Your are already in whitelist"
+				response = bot.T(handler.Language, "bot.user.alreadyYou")
 			} else {
 				u := user{
 					ID:       userID,
@@ -262,9 +263,9 @@ func (bot *Bot) processUserCommand(handler MessageHandler, params []string) erro
 				}
 
 				if bot.addUser(u, true) {
-					response = fmt.Sprintf("User <code>%v %v</code> added to whitelist", userID, username)
+					response = bot.T(handler.Language, "bot.user.added", userID, username)
 				} else {
-					response = fmt.Sprintf("User <code>%v</code> already in whitelist", userID)
+					response = bot.T(handler.Language, "bot.user.alreadyAdded", userID)
 				}
 			}
 		}
@@ -273,13 +274,14 @@ func (bot *Bot) processUserCommand(handler MessageHandler, params []string) erro
 		userID, _, response = parseUser(1)
 
 		if userID > 0 {
-			if userID == bot.config.OwnerID {
-				response = "Cannot remove my owner"
+			if userID == bot.getOwnerID() {
+				response = bot.T(handler.Language, "bot.user.cannotRemoveOwn")
 			} else {
 				if bot.deleteUser(userID) {
-					response = fmt.Sprintf("User <code>%v</code> deleted from whitelist", userID)
+					bot.refreshCommands()
+					response = bot.T(handler.Language, "bot.user.removed", userID)
 				} else {
-					response = fmt.Sprintf("User <code>%v</code> not in whitelist", userID)
+					response = bot.T(handler.Language, "bot.user.notInWhitelist", userID)
 				}
 			}
 		}
@@ -298,20 +300,20 @@ func (bot *Bot) processUserCommand(handler MessageHandler, params []string) erro
 		userID, username, response = parseUser(2)
 
 		if userID > 0 {
-			u, ok := bot.getUserByID(userID)
+			ok := bot.updateUser(userID, func(u *user) {
+				if group == "none" {
+					u.Group = ""
+				} else {
+					u.Group = userGroup(group)
+				}
+			})
 			if !ok {
-				response = fmt.Sprintf("User <code>%v</code> not exists", userID)
+				response = bot.T(handler.Language, "bot.user.notExists", userID)
 				break
 			}
+			bot.refreshCommands()
 
-			if group == "none" {
-				u.Group = ""
-			} else {
-				u.Group = userGroup(group)
-			}
-			bot.addUser(*u, false)
-
-			response = fmt.Sprintf("User <code>%v %v</code> set to <code>%v</code>", userID, username, group)
+			response = bot.T(handler.Language, "bot.user.groupSet", userID, username, group)
 		}
 
 	case "email":
@@ -324,55 +326,60 @@ func (bot *Bot) processUserCommand(handler MessageHandler, params []string) erro
 		userID, username, response = parseUser(2)
 
 		if userID > 0 {
-			u, ok := bot.getUserByID(userID)
-			if !ok {
-				response = fmt.Sprintf("User <code>%v</code> not exists", userID)
-				break
+			if email == "none" {
+				email = ""
 			}
 
-			if email == "none" {
-				u.Email = ""
-				email = "(none)"
-			} else {
+			ok := bot.updateUser(userID, func(u *user) {
 				u.Email = email
+			})
+			if !ok {
+				response = bot.T(handler.Language, "bot.user.notExists", userID)
+				break
 			}
 
-			bot.addUser(*u, false)
+			if email == "" {
+				email = bot.T(handler.Language, "bot.user.emailNone")
+			}
 
-			response = fmt.Sprintf("User <code>%v %v</code> email: <code>%v</code>", userID, username, email)
+			response = bot.T(handler.Language, "bot.user.emailSet", userID, username, html.EscapeString(email))
 		}
 
 	case "list":
-		response = "Users list:\n\n"
+		// username ed e-mail possono contenere caratteri da sottoporre a escape
+		opt := bot.NewMessageResponseOpt()
+		list := bot.NewMarkup(opt).Text(bot.T(handler.Language, "bot.user.list"))
 
-		for _, u := range bot.lookupUsers {
-			response += "<code>" + fmt.Sprint(u.ID) + "</code>"
+		for _, u := range bot.listUsers() {
+			list.Code(fmt.Sprint(u.ID))
 
 			if u.Username != "" {
-				response += " <b>" + u.Username + "</b>"
+				list.Text(" ").Bold(u.Username)
 			}
 
 			if u.Email != "" {
-				response += " " + u.Email
+				list.Text(" " + u.Email)
 			}
 
 			switch u.Group {
 			case groupOwner:
-				response += " <code>★</code>"
+				list.Text(" ").Code("★")
 			case groupAdmin:
-				response += " <code>☆</code>"
+				list.Text(" ").Code("☆")
 			}
 
 			if u.PrivateChatID == 0 {
-				response += " <i>(pending)</i>"
+				list.Text(" ").Italic(bot.T(handler.Language, "bot.user.pending"))
 			} else {
-				response += " (active)"
+				list.Text(" " + bot.T(handler.Language, "bot.user.active"))
 			}
 
-			response += "\n"
-			//log.Println(response)
+			list.Line()
 		}
 
+		bot.SendMarkupResponseToPrivate(handler, list, opt)
+		return nil
+
 	default:
 		showHelp()
 	}
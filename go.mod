@@ -3,6 +3,10 @@ module github.com/marcozaccari/AssistantBot
 go 1.14
 
 require (
+	github.com/BurntSushi/toml v1.2.1
 	github.com/go-telegram-bot-api/telegram-bot-api v4.6.4+incompatible
 	github.com/technoweenie/multipartstreamer v1.0.1 // indirect
+	go.etcd.io/bbolt v1.3.6
+	golang.org/x/sys v0.7.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
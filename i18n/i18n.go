@@ -0,0 +1,140 @@
+// Package i18n - catalogo dei messaggi tradotti, con fallback sulla lingua
+// base (es. "it-IT" -> "it") e sulla lingua di default.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Catalog - messaggi per lingua; può essere usato da più goroutine
+type Catalog struct {
+	lock            sync.RWMutex
+	defaultLanguage string
+	messages        map[string]map[string]string // lingua -> chiave -> messaggio
+}
+
+// NewCatalog - crea un catalogo vuoto; defaultLanguage viene usata per i messaggi
+// non tradotti nella lingua richiesta
+func NewCatalog(defaultLanguage string) *Catalog {
+	return &Catalog{
+		defaultLanguage: Normalize(defaultLanguage),
+		messages:        make(map[string]map[string]string),
+	}
+}
+
+// Normalize - codice di lingua in minuscolo con il trattino (es. "pt_BR" -> "pt-br")
+func Normalize(lang string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(lang), "_", "-", -1))
+}
+
+// base - lingua senza la regione (es. "it-ch" -> "it")
+func base(lang string) string {
+	if i := strings.Index(lang, "-"); i > 0 {
+		return lang[:i]
+	}
+	return lang
+}
+
+// DefaultLanguage - lingua di default del catalogo
+func (c *Catalog) DefaultLanguage() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.defaultLanguage
+}
+
+// SetDefaultLanguage - imposta la lingua di default del catalogo
+func (c *Catalog) SetDefaultLanguage(lang string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.defaultLanguage = Normalize(lang)
+}
+
+// Add - aggiunge (o sostituisce) i messaggi della lingua
+func (c *Catalog) Add(lang string, messages map[string]string) {
+	lang = Normalize(lang)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	bundle, ok := c.messages[lang]
+	if !ok {
+		bundle = make(map[string]string)
+		c.messages[lang] = bundle
+	}
+
+	for key, message := range messages {
+		bundle[key] = message
+	}
+}
+
+// Languages - lingue presenti nel catalogo, in ordine alfabetico
+func (c *Catalog) Languages() []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	langs := make([]string, 0, len(c.messages))
+	for lang := range c.messages {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	return langs
+}
+
+// Supported - ritorna la lingua del catalogo corrispondente a lang (eventualmente
+// quella base) e true, oppure "" e false se non presente
+func (c *Catalog) Supported(lang string) (string, bool) {
+	lang = Normalize(lang)
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if _, ok := c.messages[lang]; ok {
+		return lang, true
+	}
+	if _, ok := c.messages[base(lang)]; ok {
+		return base(lang), true
+	}
+
+	return "", false
+}
+
+// Lookup - messaggio della chiave nella lingua, senza fallback
+func (c *Catalog) Lookup(lang string, key string) (string, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	message, ok := c.messages[Normalize(lang)][key]
+	return message, ok
+}
+
+// Translate - messaggio della chiave nella lingua, cercato nell'ordine nella lingua,
+// nella lingua base e nella lingua di default; se non presente ritorna la chiave.
+// Con args il messaggio viene formattato con fmt.Sprintf.
+func (c *Catalog) Translate(lang string, key string, args ...interface{}) string {
+	lang = Normalize(lang)
+
+	c.lock.RLock()
+	message, ok := c.messages[lang][key]
+	if !ok {
+		message, ok = c.messages[base(lang)][key]
+	}
+	if !ok {
+		message, ok = c.messages[c.defaultLanguage][key]
+	}
+	c.lock.RUnlock()
+
+	if !ok {
+		message = key
+	}
+
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
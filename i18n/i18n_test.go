@@ -0,0 +1,72 @@
+package i18n
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	c := NewCatalog("en")
+
+	c.Add("en", map[string]string{
+		"hello":   "Hello",
+		"silence": "Silenced for %v minutes",
+		"only.en": "English only",
+	})
+	c.Add("it", map[string]string{
+		"hello":   "Ciao",
+		"silence": "Silenzio per %v minuti",
+	})
+	c.Add("pt_BR", map[string]string{
+		"hello": "Olá",
+	})
+
+	tests := []struct {
+		lang string
+		key  string
+		args []interface{}
+		want string
+	}{
+		{"it", "hello", nil, "Ciao"},
+		{"IT-ch", "hello", nil, "Ciao"},
+		{"it", "silence", []interface{}{30}, "Silenzio per 30 minuti"},
+		{"it", "only.en", nil, "English only"},
+		{"de", "hello", nil, "Hello"},
+		{"", "hello", nil, "Hello"},
+		{"pt-br", "hello", nil, "Olá"},
+		{"it", "missing", nil, "missing"},
+	}
+
+	for _, test := range tests {
+		got := c.Translate(test.lang, test.key, test.args...)
+		if got != test.want {
+			t.Errorf("Translate(%q, %q) = %q, want %q", test.lang, test.key, got, test.want)
+		}
+	}
+
+	if lang, ok := c.Supported("it-IT"); !ok || lang != "it" {
+		t.Errorf("Supported(it-IT) = %q %v, want it true", lang, ok)
+	}
+	if _, ok := c.Supported("de"); ok {
+		t.Error("Supported(de) should be false")
+	}
+
+	if _, ok := c.Lookup("it", "only.en"); ok {
+		t.Error("Lookup should not fall back to the default language")
+	}
+
+	if langs := c.Languages(); !reflect.DeepEqual(langs, []string{"en", "it", "pt-br"}) {
+		t.Error("Unexpected languages:", langs)
+	}
+
+	// le aggiunte successive integrano la lingua
+	c.Add("it", map[string]string{"only.en": "Anche in italiano"})
+	if got := c.Translate("it", "only.en"); got != "Anche in italiano" {
+		t.Error("Unexpected translation after Add:", got)
+	}
+
+	c.SetDefaultLanguage("it")
+	if got := c.Translate("de", "hello"); got != "Ciao" {
+		t.Error("Unexpected fallback after SetDefaultLanguage:", got)
+	}
+}
@@ -0,0 +1,59 @@
+// Package fileutil - funzioni di supporto per la scrittura sicura dei file.
+package fileutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteAtomic - scrive il file in modo atomico: file temporaneo nella stessa directory,
+// sync su disco e rename sul file di destinazione
+func WriteAtomic(filename string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(filename)
+
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := f.Name()
+
+	// in caso di errore il file temporaneo viene rimosso
+	fail := func(err error) error {
+		f.Close()
+		os.Remove(tmpName)
+		return err
+	}
+
+	_, err = f.Write(data)
+	if err != nil {
+		return fail(err)
+	}
+	err = f.Chmod(perm)
+	if err != nil {
+		return fail(err)
+	}
+	err = f.Sync()
+	if err != nil {
+		return fail(err)
+	}
+	err = f.Close()
+	if err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	err = os.Rename(tmpName, filename)
+	if err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	// rende persistente il rename (non supportato su tutti i sistemi)
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+// Command gendefaultconfig - genera bot/defaultconfig.go a partire da
+// settings.default.json, unica fonte della configurazione di default.
+//
+// Uso (da bot/, tramite go generate):
+//
+//	go run ../internal/gendefaultconfig ../settings.default.json defaultconfig.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const header = `// Code generated by gendefaultconfig from %s; DO NOT EDIT.
+
+package bot
+
+// DefaultConfigContent - contenuto del file di configurazione creato al primo avvio
+// se non viene trovato (vedi Bot.DefaultConfig); generato da %s
+var DefaultConfigContent = []byte(`
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintln(os.Stderr, "usage: gendefaultconfig settings.default.json defaultconfig.go")
+		os.Exit(2)
+	}
+
+	err := generate(os.Args[1], os.Args[2])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gendefaultconfig:", err)
+		os.Exit(1)
+	}
+}
+
+func generate(source string, output string) error {
+	content, err := ioutil.ReadFile(source)
+	if err != nil {
+		return err
+	}
+
+	// il contenuto viene scritto in una raw string
+	if bytes.ContainsRune(content, '`') {
+		return fmt.Errorf("%s: backquotes are not supported", source)
+	}
+
+	name := filepath.Base(source)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, header, name, name)
+	b.WriteString("`")
+	b.Write(content)
+	b.WriteString("`)\n")
+
+	return ioutil.WriteFile(output, []byte(b.String()), 0644)
+}
@@ -0,0 +1,118 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// chiave usata, come in slog, per i valori senza chiave
+const badKey = "!BADKEY"
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+// converte gli argomenti chiave-valore in campi
+func pairs(args []interface{}) []field {
+	var fields []field
+
+	for i := 0; i < len(args); i++ {
+		key, ok := args[i].(string)
+		if !ok || i == len(args)-1 {
+			fields = append(fields, field{badKey, args[i]})
+			continue
+		}
+
+		fields = append(fields, field{key, args[i+1]})
+		i++
+	}
+
+	return fields
+}
+
+// valore da serializzare: errori e Stringer come testo
+func plainValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case time.Duration:
+		return v.String()
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	return value
+}
+
+func formatText(fields []field) []byte {
+	var buf bytes.Buffer
+
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+
+		buf.WriteString(f.key)
+		buf.WriteByte('=')
+
+		var s string
+		switch v := plainValue(f.value).(type) {
+		case string:
+			s = v
+		case []byte:
+			s = string(v)
+		default:
+			s = fmt.Sprint(v)
+		}
+
+		if needsQuoting(s) {
+			s = strconv.Quote(s)
+		}
+		buf.WriteString(s)
+	}
+
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+func needsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+
+	return strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r)
+	}) >= 0
+}
+
+func formatJSON(fields []field) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(f.key)
+		buf.Write(key)
+		buf.WriteByte(':')
+
+		value, err := json.Marshal(plainValue(f.value))
+		if err != nil {
+			value, _ = json.Marshal(fmt.Sprint(f.value))
+		}
+		buf.Write(value)
+	}
+	buf.WriteString("}\n")
+
+	return buf.Bytes()
+}
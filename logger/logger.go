@@ -0,0 +1,151 @@
+// Package logger - log strutturati a livelli, con un'interfaccia compatibile
+// con *slog.Logger (log/slog) in modo che possa essere usato al posto di quello di default.
+package logger
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Logger - interfaccia implementata anche da *slog.Logger.
+// args sono coppie chiave-valore (es. "chat_id", 123) aggiunte alla riga.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// Level - livello di log, con gli stessi valori di slog.Level
+type Level int
+
+const (
+	LevelDebug Level = -4
+	LevelInfo  Level = 0
+	LevelWarn  Level = 4
+	LevelError Level = 8
+)
+
+func (l Level) String() string {
+	switch {
+	case l >= LevelError:
+		return "ERROR"
+	case l >= LevelWarn:
+		return "WARN"
+	case l >= LevelInfo:
+		return "INFO"
+	default:
+		return "DEBUG"
+	}
+}
+
+// Options - opzioni del logger di default
+type Options struct {
+	Level Level // livello minimo delle righe scritte
+	JSON  bool  // una riga JSON per evento anzichè chiave=valore
+}
+
+// New - ritorna il logger di default, che scrive su w una riga per evento
+// nel formato di slog.TextHandler (time=... level=... msg=... chiave=valore)
+// oppure di slog.JSONHandler
+func New(w io.Writer, opts Options) Logger {
+	return &logger{
+		out:   w,
+		lock:  &sync.Mutex{},
+		level: opts.Level,
+		json:  opts.JSON,
+	}
+}
+
+// Default - logger di default su stderr
+func Default(level Level) Logger {
+	return New(os.Stderr, Options{Level: level})
+}
+
+// Discard - logger che non scrive nulla
+var Discard Logger = discard{}
+
+// With - ritorna un logger che aggiunge args ad ogni riga
+func With(l Logger, args ...interface{}) Logger {
+	if len(args) == 0 {
+		return l
+	}
+
+	if w, ok := l.(interface {
+		With(args ...interface{}) Logger
+	}); ok {
+		return w.With(args...)
+	}
+
+	return &withLogger{l, args}
+}
+
+type logger struct {
+	out   io.Writer
+	lock  *sync.Mutex // condiviso con i logger derivati tramite With
+	level Level
+	json  bool
+	args  []interface{}
+}
+
+func (l *logger) Debug(msg string, args ...interface{}) { l.log(LevelDebug, msg, args) }
+func (l *logger) Info(msg string, args ...interface{})  { l.log(LevelInfo, msg, args) }
+func (l *logger) Warn(msg string, args ...interface{})  { l.log(LevelWarn, msg, args) }
+func (l *logger) Error(msg string, args ...interface{}) { l.log(LevelError, msg, args) }
+
+func (l *logger) With(args ...interface{}) Logger {
+	w := *l
+	w.args = append(append([]interface{}{}, l.args...), args...)
+	return &w
+}
+
+func (l *logger) log(level Level, msg string, args []interface{}) {
+	if level < l.level {
+		return
+	}
+
+	fields := append([]field{
+		{"time", time.Now()},
+		{"level", level.String()},
+		{"msg", msg},
+	}, pairs(append(append([]interface{}{}, l.args...), args...))...)
+
+	var line []byte
+	if l.json {
+		line = formatJSON(fields)
+	} else {
+		line = formatText(fields)
+	}
+
+	l.lock.Lock()
+	l.out.Write(line)
+	l.lock.Unlock()
+}
+
+// logger con campi aggiuntivi, per le implementazioni senza With
+type withLogger struct {
+	l    Logger
+	args []interface{}
+}
+
+func (w *withLogger) Debug(msg string, args ...interface{}) { w.l.Debug(msg, w.merge(args)...) }
+func (w *withLogger) Info(msg string, args ...interface{})  { w.l.Info(msg, w.merge(args)...) }
+func (w *withLogger) Warn(msg string, args ...interface{})  { w.l.Warn(msg, w.merge(args)...) }
+func (w *withLogger) Error(msg string, args ...interface{}) { w.l.Error(msg, w.merge(args)...) }
+
+func (w *withLogger) With(args ...interface{}) Logger {
+	return &withLogger{w.l, w.merge(args)}
+}
+
+func (w *withLogger) merge(args []interface{}) []interface{} {
+	return append(append([]interface{}{}, w.args...), args...)
+}
+
+type discard struct{}
+
+func (discard) Debug(msg string, args ...interface{}) {}
+func (discard) Info(msg string, args ...interface{})  {}
+func (discard) Warn(msg string, args ...interface{})  {}
+func (discard) Error(msg string, args ...interface{}) {}
@@ -0,0 +1,68 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	var buf bytes.Buffer
+
+	l := New(&buf, Options{Level: LevelInfo})
+	l = With(l, "processor", "Bot")
+
+	l.Debug("hidden")
+	l.Info("Command processed", "chat_id", int64(-100), "command", "user list", "err", errors.New("failed"))
+
+	line := buf.String()
+	if strings.Contains(line, "hidden") {
+		t.Error("Debug line written at info level")
+	}
+	for _, s := range []string{"level=INFO", `msg="Command processed"`, "processor=Bot", "chat_id=-100",
+		`command="user list"`, "err=failed"} {
+		if !strings.Contains(line, s) {
+			t.Error("Missing", s, "in", line)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var buf bytes.Buffer
+
+	l := New(&buf, Options{Level: LevelDebug, JSON: true})
+	l.Warn("Cannot send message", "user_id", 5, "odd")
+
+	var event map[string]interface{}
+	err := json.Unmarshal(buf.Bytes(), &event)
+	if err != nil {
+		t.Fatal("Invalid JSON line:", buf.String(), err)
+	}
+
+	if event["level"] != "WARN" || event["msg"] != "Cannot send message" ||
+		event["user_id"] != float64(5) || event[badKey] != "odd" {
+		t.Error("Unexpected event:", event)
+	}
+}
+
+type recordLogger struct {
+	args []interface{}
+}
+
+func (r *recordLogger) Debug(msg string, args ...interface{}) { r.args = args }
+func (r *recordLogger) Info(msg string, args ...interface{})  { r.args = args }
+func (r *recordLogger) Warn(msg string, args ...interface{})  { r.args = args }
+func (r *recordLogger) Error(msg string, args ...interface{}) { r.args = args }
+
+func TestWith(t *testing.T) {
+	r := &recordLogger{}
+
+	l := With(With(r, "a", 1), "b", 2)
+	l.Info("msg", "c", 3)
+
+	if len(r.args) != 6 || r.args[0] != "a" || r.args[2] != "b" || r.args[4] != "c" {
+		t.Error("Unexpected args:", r.args)
+	}
+}
@@ -0,0 +1,232 @@
+// Package markup - costruzione di messaggi Telegram formattati in HTML o MarkdownV2,
+// con l'escape corretto per la modalità scelta e la versione in testo semplice
+// da inviare se Telegram rifiuta la formattazione.
+package markup
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Mode - parse mode di Telegram
+type Mode string
+
+const (
+	HTML       Mode = "HTML"
+	MarkdownV2 Mode = "MarkdownV2"
+)
+
+// Builder - messaggio formattato; i metodi ritornano il builder per concatenarli
+// (es. markup.New(markup.HTML).Bold("Utenti").Line().Text(nome))
+type Builder struct {
+	mode  Mode
+	text  strings.Builder
+	plain strings.Builder
+}
+
+// New - crea un builder per la modalità
+func New(mode Mode) *Builder {
+	return &Builder{mode: mode}
+}
+
+// Mode - modalità del builder (da usare come ParseMode)
+func (b *Builder) Mode() Mode {
+	return b.mode
+}
+
+// String - testo formattato
+func (b *Builder) String() string {
+	return b.text.String()
+}
+
+// Plain - testo senza formattazione
+func (b *Builder) Plain() string {
+	return b.plain.String()
+}
+
+// Len - lunghezza in caratteri del testo senza formattazione
+func (b *Builder) Len() int {
+	return utf8.RuneCountInString(b.plain.String())
+}
+
+func (b *Builder) write(formatted string, plain string) *Builder {
+	b.text.WriteString(formatted)
+	b.plain.WriteString(plain)
+	return b
+}
+
+// Text - testo semplice
+func (b *Builder) Text(s string) *Builder {
+	return b.write(b.Escape(s), s)
+}
+
+// Line - a capo
+func (b *Builder) Line() *Builder {
+	return b.write("\n", "\n")
+}
+
+// Bold - testo in grassetto
+func (b *Builder) Bold(s string) *Builder {
+	if b.mode == HTML {
+		return b.write("<b>"+html.EscapeString(s)+"</b>", s)
+	}
+	return b.write("*"+EscapeMarkdownV2(s)+"*", s)
+}
+
+// Italic - testo in corsivo
+func (b *Builder) Italic(s string) *Builder {
+	if b.mode == HTML {
+		return b.write("<i>"+html.EscapeString(s)+"</i>", s)
+	}
+	return b.write("_"+EscapeMarkdownV2(s)+"_", s)
+}
+
+// Code - testo a spaziatura fissa
+func (b *Builder) Code(s string) *Builder {
+	if b.mode == HTML {
+		return b.write("<code>"+html.EscapeString(s)+"</code>", s)
+	}
+	return b.write("`"+escapeMarkdownV2Code(s)+"`", s)
+}
+
+// Pre - blocco preformattato; lang (facoltativo) è il linguaggio per l'evidenziazione
+func (b *Builder) Pre(s string, lang string) *Builder {
+	if b.mode == HTML {
+		if lang != "" {
+			return b.write(`<pre><code class="language-`+html.EscapeString(lang)+`">`+html.EscapeString(s)+"</code></pre>", s)
+		}
+		return b.write("<pre>"+html.EscapeString(s)+"</pre>", s)
+	}
+	return b.write("```"+lang+"\n"+escapeMarkdownV2Code(s)+"\n```", s)
+}
+
+// Link - collegamento
+func (b *Builder) Link(text string, url string) *Builder {
+	if b.mode == HTML {
+		return b.write(`<a href="`+html.EscapeString(url)+`">`+html.EscapeString(text)+"</a>", text+" ("+url+")")
+	}
+	return b.write("["+EscapeMarkdownV2(text)+"]("+escapeMarkdownV2URL(url)+")", text+" ("+url+")")
+}
+
+// Mention - menzione di un utente tramite ID, anche senza username
+func (b *Builder) Mention(text string, userID int) *Builder {
+	url := "tg://user?id=" + strconv.Itoa(userID)
+
+	if b.mode == HTML {
+		return b.write(`<a href="`+url+`">`+html.EscapeString(text)+"</a>", text)
+	}
+	return b.write("["+EscapeMarkdownV2(text)+"]("+url+")", text)
+}
+
+// Table - tabella a colonne allineate in un blocco preformattato;
+// la prima riga può essere l'intestazione
+func (b *Builder) Table(rows [][]string) *Builder {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			if n := utf8.RuneCountInString(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	var lines []string
+	for _, row := range rows {
+		var line string
+		for i, cell := range row {
+			if i < len(row)-1 {
+				cell += strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)+2)
+			}
+			line += cell
+		}
+		lines = append(lines, line)
+	}
+
+	return b.Pre(strings.Join(lines, "\n"), "")
+}
+
+// Escape - escape del testo per la modalità del builder
+func (b *Builder) Escape(s string) string {
+	if b.mode == HTML {
+		return html.EscapeString(s)
+	}
+	return EscapeMarkdownV2(s)
+}
+
+// caratteri riservati di MarkdownV2 al di fuori di code e pre
+const markdownV2Special = "_*[]()~`>#+-=|{}.!\\"
+
+// EscapeMarkdownV2 - escape del testo semplice per MarkdownV2
+func EscapeMarkdownV2(s string) string {
+	return escapeChars(s, markdownV2Special)
+}
+
+// in code e pre vanno preceduti da \ soltanto ` e \
+func escapeMarkdownV2Code(s string) string {
+	return escapeChars(s, "`\\")
+}
+
+// nell'URL di un link vanno preceduti da \ soltanto ) e \
+func escapeMarkdownV2URL(s string) string {
+	return escapeChars(s, ")\\")
+}
+
+func escapeChars(s string, chars string) string {
+	var out strings.Builder
+
+	for _, r := range s {
+		if strings.ContainsRune(chars, r) {
+			out.WriteByte('\\')
+		}
+		out.WriteRune(r)
+	}
+
+	return out.String()
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// StripHTML - testo semplice da un messaggio formattato in HTML
+func StripHTML(s string) string {
+	return html.UnescapeString(htmlTag.ReplaceAllString(s, ""))
+}
+
+// StripMarkdownV2 - testo semplice (approssimato) da un messaggio formattato in MarkdownV2:
+// vengono rimossi gli escape e i delimitatori non preceduti da \
+func StripMarkdownV2(s string) string {
+	var out strings.Builder
+
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			out.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case strings.ContainsRune("*_~`|", r):
+			// delimitatore di formattazione
+		default:
+			out.WriteRune(r)
+		}
+	}
+
+	return out.String()
+}
+
+// Strip - testo semplice da un messaggio formattato nella modalità
+func Strip(s string, mode Mode) string {
+	switch mode {
+	case HTML:
+		return StripHTML(s)
+	case MarkdownV2:
+		return StripMarkdownV2(s)
+	}
+	return s
+}
@@ -0,0 +1,72 @@
+package markup
+
+import (
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	tests := []struct {
+		mode  Mode
+		build func(b *Builder)
+		want  string
+		plain string
+	}{
+		{
+			HTML,
+			func(b *Builder) { b.Bold("<Mario & co>").Text(" 1<2").Line().Code("a&b") },
+			"<b>&lt;Mario &amp; co&gt;</b> 1&lt;2\n<code>a&amp;b</code>",
+			"<Mario & co> 1<2\na&b",
+		},
+		{
+			MarkdownV2,
+			func(b *Builder) { b.Bold("mario_rossi").Text(" (v1.0)!").Line().Code("a`b\\c") },
+			"*mario\\_rossi* \\(v1\\.0\\)\\!\n`a\\`b\\\\c`",
+			"mario_rossi (v1.0)!\na`b\\c",
+		},
+		{
+			HTML,
+			func(b *Builder) { b.Mention("<Mario>", 123).Text(" ").Link("docs", "https://x.y/?a=1&b=2") },
+			`<a href="tg://user?id=123">&lt;Mario&gt;</a> <a href="https://x.y/?a=1&amp;b=2">docs</a>`,
+			"<Mario> docs (https://x.y/?a=1&b=2)",
+		},
+		{
+			MarkdownV2,
+			func(b *Builder) { b.Mention("[Mario]", 123).Text(" ").Link("docs", "https://x.y/(a)") },
+			"[\\[Mario\\]](tg://user?id=123) [docs](https://x.y/(a\\))",
+			"[Mario] docs (https://x.y/(a))",
+		},
+		{
+			HTML,
+			func(b *Builder) { b.Pre("if a < b {}", "go") },
+			`<pre><code class="language-go">if a &lt; b {}</code></pre>`,
+			"if a < b {}",
+		},
+		{
+			MarkdownV2,
+			func(b *Builder) { b.Table([][]string{{"ID", "Username"}, {"1234", "mario_rossi"}, {"5", "è"}}) },
+			"```\nID    Username\n1234  mario_rossi\n5     è\n```",
+			"ID    Username\n1234  mario_rossi\n5     è",
+		},
+	}
+
+	for i, test := range tests {
+		b := New(test.mode)
+		test.build(b)
+
+		if got := b.String(); got != test.want {
+			t.Errorf("%d: String() = %q, want %q", i, got, test.want)
+		}
+		if got := b.Plain(); got != test.plain {
+			t.Errorf("%d: Plain() = %q, want %q", i, got, test.plain)
+		}
+	}
+}
+
+func TestStrip(t *testing.T) {
+	if got := Strip("<b>Mario &amp; co</b> <code>1&lt;2</code>", HTML); got != "Mario & co 1<2" {
+		t.Error("Unexpected HTML strip:", got)
+	}
+	if got := Strip("*mario\\_rossi* \\(v1\\.0\\)", MarkdownV2); got != "mario_rossi (v1.0)" {
+		t.Error("Unexpected MarkdownV2 strip:", got)
+	}
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	r := NewRegistry()
+
+	updates := r.Counter("bot_updates_total", "Updates received")
+	commands := r.Counter("bot_commands_total", "Commands processed", "processor", "command")
+	latency := r.Histogram("bot_duration_seconds", "Processing latency", []float64{0.1, 1}, "processor")
+	r.GaugeFunc("bot_backlog", "Pending updates", func() float64 { return 3 })
+
+	updates.Inc()
+	updates.Add(2)
+	commands.Inc("Bot", "ping")
+	commands.Inc("My \"scope\"", "hello")
+	latency.Observe(0.05, "Bot")
+	latency.Observe(0.5, "Bot")
+	latency.Observe(5, "Bot")
+
+	var buf bytes.Buffer
+	err := r.Write(&buf)
+	if err != nil {
+		t.Fatal("Error writing metrics:", err)
+	}
+
+	out := buf.String()
+	for _, line := range []string{
+		"# TYPE bot_updates_total counter",
+		"bot_updates_total 3",
+		`bot_commands_total{processor="Bot",command="ping"} 1`,
+		`bot_commands_total{processor="My \"scope\"",command="hello"} 1`,
+		"# TYPE bot_duration_seconds histogram",
+		`bot_duration_seconds_bucket{processor="Bot",le="0.1"} 1`,
+		`bot_duration_seconds_bucket{processor="Bot",le="1"} 2`,
+		`bot_duration_seconds_bucket{processor="Bot",le="+Inf"} 3`,
+		`bot_duration_seconds_sum{processor="Bot"} 5.55`,
+		`bot_duration_seconds_count{processor="Bot"} 3`,
+		"bot_backlog 3",
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Error("Missing line", line, "in\n"+out)
+		}
+	}
+
+	if commands.Value("Bot", "ping") != 1 {
+		t.Error("Unexpected counter value")
+	}
+}
@@ -0,0 +1,71 @@
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/marcozaccari/AssistantBot/internal/fileutil"
+)
+
+// permessi dei file scritti: le impostazioni possono contenere dati riservati (es. token)
+const fileMode os.FileMode = 0600
+
+// numero di default di copie di backup mantenute
+const defaultBackups = 3
+
+// nome della copia di backup n (1 = la più recente)
+func (set *Settings) backupName(n int) string {
+	return set.filename + ".bak." + strconv.Itoa(n)
+}
+
+// ruota le copie di backup e salva content come la più recente
+func (set *Settings) rotateBackups(content []byte) error {
+	if set.Backups <= 0 {
+		return nil
+	}
+
+	os.Remove(set.backupName(set.Backups))
+	for n := set.Backups - 1; n >= 1; n-- {
+		os.Rename(set.backupName(n), set.backupName(n+1))
+	}
+
+	return fileutil.WriteAtomic(set.backupName(1), content, fileMode)
+}
+
+// true se il contenuto è interpretabile dal codec (i file corrotti non vengono salvati come backup)
+func (set *Settings) isValidContent(content []byte) bool {
+	if len(bytes.TrimSpace(content)) == 0 {
+		return false
+	}
+
+	data, err := set.Codec.Decode(content)
+	return err == nil && json.Valid(data)
+}
+
+// carica la copia di backup valida più recente; ritorna false se nessuna è utilizzabile
+func (set *Settings) recoverFromBackup() bool {
+	for n := 1; n <= set.Backups; n++ {
+		name := set.backupName(n)
+
+		content, err := ioutil.ReadFile(name)
+		if err != nil {
+			continue
+		}
+
+		result, err := set.decode(content, true)
+		if err != nil {
+			set.log().Warn("Invalid settings backup", "file", name, "err", err)
+			continue
+		}
+
+		set.apply(result)
+		set.log().Warn("Settings recovered from backup", "file", name)
+
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,333 @@
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Codec - converte il formato del file da e verso JSON, che viene usato
+// internamente per deserializzare le sezioni.
+type Codec interface {
+	// Decode converte il contenuto del file in JSON
+	Decode(content []byte) ([]byte, error)
+
+	// Encode converte i dati JSON nel formato del file.
+	// original è il contenuto attuale del file (vuoto se non esiste),
+	// utilizzabile per preservarne la formattazione.
+	Encode(data []byte, original []byte) ([]byte, error)
+}
+
+var codecsLock sync.Mutex
+
+// chiave: estensione del file (con il punto, minuscola)
+var codecs = map[string]Codec{
+	".json": JSONCodec{},
+	".yaml": YAMLCodec{},
+	".yml":  YAMLCodec{},
+	".toml": TOMLCodec{},
+}
+
+// RegisterCodec - associa un codec ad un'estensione di file (es. ".ini")
+func RegisterCodec(ext string, codec Codec) {
+	codecsLock.Lock()
+	defer codecsLock.Unlock()
+
+	codecs[strings.ToLower(ext)] = codec
+}
+
+// CodecFor - ritorna il codec associato all'estensione del file; JSON se sconosciuta
+func CodecFor(filename string) Codec {
+	codecsLock.Lock()
+	defer codecsLock.Unlock()
+
+	codec, ok := codecs[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return JSONCodec{}
+	}
+
+	return codec
+}
+
+// JSONCodec - JSON con commenti e virgole finali (JSONC).
+// Il salvataggio preserva commenti e ordine delle chiavi del file esistente.
+type JSONCodec struct{}
+
+func (JSONCodec) Decode(content []byte) ([]byte, error) {
+	return StripJSONC(content), nil
+}
+
+func (JSONCodec) Encode(data []byte, original []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(original)) > 0 {
+		patched, err := patchJSONC(original, data)
+		if err == nil {
+			return patched, nil
+		}
+		// file esistente non interpretabile: viene riscritto
+	}
+
+	var buf bytes.Buffer
+	err := json.Indent(&buf, data, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// YAMLCodec - YAML; le chiavi mantengono l'ordine dei dati.
+// Il salvataggio preserva commenti e ordine delle chiavi del file esistente.
+type YAMLCodec struct{}
+
+func (YAMLCodec) Decode(content []byte) ([]byte, error) {
+	var data interface{}
+
+	err := yaml.Unmarshal(content, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(data)
+}
+
+func (YAMLCodec) Encode(data []byte, original []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	node, err := jsonToYAMLNode(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bytes.TrimSpace(original)) > 0 {
+		var doc yaml.Node
+		err := yaml.Unmarshal(original, &doc)
+		// file esistente non interpretabile: viene riscritto
+		if err == nil && doc.Kind == yaml.DocumentNode && len(doc.Content) == 1 {
+			patchYAMLNode(doc.Content[0], node)
+			node = &doc
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+
+	err = enc.Encode(node)
+	if err != nil {
+		return nil, err
+	}
+	enc.Close()
+
+	return buf.Bytes(), nil
+}
+
+// patchYAMLNode - applica al nodo del file esistente i valori di data, mantenendo
+// i commenti. Come per JSONC le chiavi nuove vengono aggiunte in coda alla mappa,
+// quelle non più presenti vengono rimosse e i valori invariati non vengono toccati.
+func patchYAMLNode(orig *yaml.Node, data *yaml.Node) {
+	if orig.Kind == yaml.MappingNode && data.Kind == yaml.MappingNode {
+		patchYAMLMapping(orig, data)
+		return
+	}
+
+	if equalYAMLNodes(orig, data) {
+		return
+	}
+
+	// valore cambiato: restano soltanto i commenti
+	orig.Kind = data.Kind
+	orig.Style = 0
+	orig.Tag = data.Tag
+	orig.Value = data.Value
+	orig.Anchor = ""
+	orig.Alias = nil
+	orig.Content = data.Content
+}
+
+func patchYAMLMapping(orig *yaml.Node, data *yaml.Node) {
+	// chiave del file corrispondente a quella dei dati: prima esatta, poi senza
+	// distinzione tra maiuscole e minuscole (come nel caricamento)
+	origKey := func(key string) int {
+		for i := 0; i+1 < len(orig.Content); i += 2 {
+			if orig.Content[i].Value == key {
+				return i
+			}
+		}
+		for i := 0; i+1 < len(orig.Content); i += 2 {
+			if strings.EqualFold(orig.Content[i].Value, key) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	matched := make(map[int]bool)
+	var added []*yaml.Node
+
+	for i := 0; i+1 < len(data.Content); i += 2 {
+		key, value := data.Content[i], data.Content[i+1]
+
+		j := origKey(key.Value)
+		if j < 0 || matched[j] {
+			added = append(added, key, value)
+			continue
+		}
+
+		matched[j] = true
+		patchYAMLNode(orig.Content[j+1], value)
+	}
+
+	var content []*yaml.Node
+	for i := 0; i+1 < len(orig.Content); i += 2 {
+		if matched[i] {
+			content = append(content, orig.Content[i], orig.Content[i+1])
+		}
+	}
+	orig.Content = append(content, added...)
+}
+
+// confronta i valori rappresentati dai nodi, indipendentemente dalla formattazione
+func equalYAMLNodes(a *yaml.Node, b *yaml.Node) bool {
+	var va, vb interface{}
+
+	if a.Decode(&va) != nil || b.Decode(&vb) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(va, vb)
+}
+
+// costruisce il nodo YAML leggendo i token JSON, in modo da mantenere l'ordine delle chiavi
+func jsonToYAMLNode(dec *json.Decoder) (*yaml.Node, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if t == '[' {
+			node = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		}
+
+		for dec.More() {
+			if node.Kind == yaml.MappingNode {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content,
+					&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key.(string)})
+			}
+
+			item, err := jsonToYAMLNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, item)
+		}
+
+		// chiusura
+		_, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		return node, nil
+
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: t}, nil
+
+	case json.Number:
+		tag := "!!int"
+		if _, err := t.Int64(); err != nil {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: t.String()}, nil
+
+	case bool:
+		value := "false"
+		if t {
+			value = "true"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: value}, nil
+
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+}
+
+// TOMLCodec - TOML; i valori null non sono rappresentabili e vengono omessi.
+// Il salvataggio riscrive il file: original viene ignorato e commenti e ordine
+// delle chiavi del file esistente vanno persi.
+type TOMLCodec struct{}
+
+func (TOMLCodec) Decode(content []byte) ([]byte, error) {
+	var data map[string]interface{}
+
+	err := toml.Unmarshal(content, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(data)
+}
+
+func (TOMLCodec) Encode(data []byte, original []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var value interface{}
+	err := dec.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = toml.NewEncoder(&buf).Encode(tomlValue(value))
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// adatta i valori JSON a TOML: numeri interi come int64, null omessi
+func tomlValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if item == nil {
+				delete(v, key)
+				continue
+			}
+			v[key] = tomlValue(item)
+		}
+
+	case []interface{}:
+		items := v[:0]
+		for _, item := range v {
+			if item != nil {
+				items = append(items, tomlValue(item))
+			}
+		}
+		return items
+
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		n, _ := v.Float64()
+		return n
+	}
+
+	return value
+}
@@ -0,0 +1,73 @@
+package settings
+
+import "reflect"
+
+// copia profonda di un valore: slice, map e puntatori dei campi esportati vengono
+// duplicati, in modo che la copia non condivida memoria con l'originale.
+// I campi non esportati vengono copiati così come sono.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(deepCopy(v.Elem()))
+		return c
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem()))
+		return c
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, key := range v.MapKeys() {
+			c.SetMapIndex(deepCopy(key), deepCopy(v.MapIndex(key)))
+		}
+		return c
+
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			c.Field(i).Set(deepCopy(v.Field(i)))
+		}
+		return c
+	}
+
+	return v
+}
+
+// nuova istanza con una copia profonda dei dati puntati da current
+func copyData(current reflect.Value) reflect.Value {
+	data := reflect.New(current.Type().Elem())
+	data.Elem().Set(deepCopy(current.Elem()))
+	return data
+}
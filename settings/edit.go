@@ -0,0 +1,111 @@
+package settings
+
+// Lettura e modifica a runtime dei singoli campi (es. da comandi in chat).
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// ErrSecretField - i campi riservati non sono modificabili tramite SetValue
+var ErrSecretField = errors.New("secret field")
+
+// cerca la sezione ignorando maiuscole e minuscole; ritorna il nome registrato
+func (set *Settings) section(name string) (string, interface{}, bool) {
+	sections, _ := set.sections()
+
+	for key, data := range sections {
+		if strings.EqualFold(key, name) {
+			return key, data, true
+		}
+	}
+
+	return "", nil, false
+}
+
+// GetValue - ritorna il nome del campo e il suo valore effettivo in JSON;
+// i campi riservati sono mascherati
+func (set *Settings) GetValue(section string, field string) (string, []byte, error) {
+	name := section
+	section, data, ok := set.section(section)
+	if !ok {
+		return "", nil, errors.New("unknown section " + name)
+	}
+
+	set.dataLock.RLock()
+	defer set.dataLock.RUnlock()
+
+	v, f, ok := fieldByName(data, field)
+	if !ok {
+		return "", nil, errors.New("unknown field " + section + "." + field)
+	}
+
+	if IsSecret(f) && !v.IsZero() {
+		value, err := json.Marshal(RedactedMask)
+		return jsonFieldName(f), value, err
+	}
+
+	value, err := Redact(v.Interface())
+	return jsonFieldName(f), value, err
+}
+
+// SetValue - assegna al campo il valore rappresentato come stringa (vedi SetField).
+// La modifica viene applicata soltanto se la sezione risulta valida, e notificata
+// tramite OnChange; per renderla persistente va invocata SaveSettings.
+// Ritorna il nome del campo.
+func (set *Settings) SetValue(section string, field string, value string) (string, error) {
+	name := section
+	section, data, ok := set.section(section)
+	if !ok {
+		return "", errors.New("unknown section " + name)
+	}
+
+	current := reflect.ValueOf(data)
+	if current.Kind() != reflect.Ptr || current.IsNil() {
+		return "", errors.New("data struct must be a pointer")
+	}
+
+	set.dataLock.Lock()
+
+	// la modifica avviene su una copia, applicata solo se valida
+	old := copyData(current)
+	updated := copyData(current)
+
+	_, f, ok := fieldByName(updated.Interface(), field)
+	if !ok {
+		set.dataLock.Unlock()
+		return "", &FieldError{Section: section, Field: field, Message: "unknown field"}
+	}
+	key := jsonFieldName(f)
+
+	if IsSecret(f) {
+		set.dataLock.Unlock()
+		return key, ErrSecretField
+	}
+
+	err := SetField(updated.Interface(), key, value)
+	if err != nil {
+		set.dataLock.Unlock()
+		return key, &FieldError{Section: section, Field: key, Message: err.Error()}
+	}
+
+	if v, ok := updated.Interface().(Validator); ok {
+		err = v.Validate()
+		if err != nil {
+			set.dataLock.Unlock()
+			return key, sectionErrors(section, err)
+		}
+	}
+
+	current.Elem().Set(updated.Elem())
+
+	set.dataLock.Unlock()
+
+	if set.OnChange != nil {
+		set.OnChange(section, old.Interface(), data)
+	}
+
+	return key, nil
+}
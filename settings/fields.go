@@ -0,0 +1,135 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldByName - cerca un campo della struttura puntata da data, ignorando maiuscole
+// e minuscole; ritorna il valore assegnabile e il nome usato nella serializzazione.
+func FieldByName(data interface{}, name string) (reflect.Value, string, bool) {
+	v, f, ok := fieldByName(data, name)
+	if !ok {
+		return reflect.Value{}, "", false
+	}
+
+	return v, jsonFieldName(f), true
+}
+
+func fieldByName(data interface{}, name string) (reflect.Value, reflect.StructField, bool) {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, reflect.StructField{}, false
+	}
+	v = v.Elem()
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			// non esportato
+			continue
+		}
+
+		key := jsonFieldName(f)
+		if key == "-" {
+			continue
+		}
+
+		if strings.EqualFold(key, name) {
+			return v.Field(i), f, true
+		}
+	}
+
+	return reflect.Value{}, reflect.StructField{}, false
+}
+
+// nome del campo nella serializzazione JSON
+func jsonFieldName(f reflect.StructField) string {
+	tag := f.Tag.Get("json")
+	if tag == "" {
+		return f.Name
+	}
+
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		return f.Name
+	}
+
+	return name
+}
+
+// SetField - assegna ad un campo della struttura puntata da data il valore
+// rappresentato come stringa, convertendolo nel tipo del campo.
+// I tipi non scalari (slice, map, struct) vengono interpretati come JSON.
+func SetField(data interface{}, name string, value string) error {
+	field, _, ok := FieldByName(data, name)
+	if !ok {
+		return errors.New("unknown field " + name)
+	}
+
+	return setValue(field, value)
+}
+
+func setValue(field reflect.Value, value string) error {
+	invalid := func() error {
+		return errors.New("invalid value " + strconv.Quote(value) + " for type " + field.Type().String())
+	}
+
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return invalid()
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return invalid()
+		}
+		field.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return invalid()
+		}
+		field.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return invalid()
+		}
+		field.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return invalid()
+		}
+		field.SetFloat(n)
+
+	default:
+		// decodifica in una copia, in modo da non alterare il campo in caso di errore
+		ptr := reflect.New(field.Type())
+		err := json.Unmarshal([]byte(value), ptr.Interface())
+		if err != nil {
+			return invalid()
+		}
+		field.Set(ptr.Elem())
+	}
+
+	return nil
+}
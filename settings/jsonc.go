@@ -0,0 +1,561 @@
+package settings
+
+// Supporto JSONC: JSON con commenti (// e /* */) e virgole finali.
+// Il salvataggio non riscrive l'intero file ma modifica soltanto i valori cambiati,
+// preservando commenti, formattazione e ordine delle chiavi dell'utente.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// StripJSONC - rimuove commenti e virgole finali, restituendo JSON standard.
+func StripJSONC(content []byte) []byte {
+	out := make([]byte, 0, len(content))
+
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+
+		switch {
+		case c == '"':
+			end := skipString(content, i)
+			out = append(out, content[i:end]...)
+			i = end - 1
+
+		case c == '/' && i+1 < len(content) && (content[i+1] == '/' || content[i+1] == '*'):
+			i = skipComment(content, i) - 1
+
+		case c == ',':
+			// virgola finale: il primo carattere significativo successivo chiude il blocco
+			next := skipBlanks(content, i+1)
+			if next < len(content) && (content[next] == '}' || content[next] == ']') {
+				continue
+			}
+			out = append(out, c)
+
+		default:
+			out = append(out, c)
+		}
+	}
+
+	return out
+}
+
+// ritorna la posizione successiva alla stringa che inizia in i
+func skipString(content []byte, i int) int {
+	for j := i + 1; j < len(content); j++ {
+		switch content[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+
+	return len(content)
+}
+
+// ritorna la posizione successiva al commento che inizia in i
+func skipComment(content []byte, i int) int {
+	if content[i+1] == '/' {
+		end := bytes.IndexByte(content[i:], '\n')
+		if end < 0 {
+			return len(content)
+		}
+		return i + end
+	}
+
+	end := bytes.Index(content[i+2:], []byte("*/"))
+	if end < 0 {
+		return len(content)
+	}
+	return i + 2 + end + 2
+}
+
+// ritorna la posizione del primo carattere significativo (esclusi spazi e commenti)
+func skipBlanks(content []byte, i int) int {
+	for i < len(content) {
+		switch content[i] {
+		case ' ', '\t', '\r', '\n':
+			i++
+		case '/':
+			if i+1 < len(content) && (content[i+1] == '/' || content[i+1] == '*') {
+				i = skipComment(content, i)
+			} else {
+				return i
+			}
+		default:
+			return i
+		}
+	}
+
+	return i
+}
+
+// nodo del documento con la sua posizione nel testo
+type jsoncNode struct {
+	kind       byte // '{', '[' oppure 0 per i valori scalari
+	start, end int
+	members    []jsoncMember // solo oggetti
+}
+
+type jsoncMember struct {
+	key      string
+	start    int // inizio della chiave
+	commaEnd int // fine del membro, virgola inclusa se presente
+	hasComma bool
+	value    *jsoncNode
+}
+
+type jsoncParser struct {
+	content []byte
+	pos     int
+}
+
+// parseJSONC - analizza il documento mantenendo la posizione di ogni valore
+func parseJSONC(content []byte) (*jsoncNode, error) {
+	p := jsoncParser{content: content}
+
+	node, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	if skipBlanks(content, p.pos) != len(content) {
+		return nil, p.errorf("unexpected data after value")
+	}
+
+	return node, nil
+}
+
+func (p *jsoncParser) errorf(format string, args ...interface{}) error {
+	line := bytes.Count(p.content[:p.pos], []byte("\n")) + 1
+	return fmt.Errorf("jsonc: line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *jsoncParser) parseValue() (*jsoncNode, error) {
+	p.pos = skipBlanks(p.content, p.pos)
+	if p.pos >= len(p.content) {
+		return nil, p.errorf("unexpected end of data")
+	}
+
+	node := &jsoncNode{start: p.pos}
+
+	switch c := p.content[p.pos]; c {
+	case '{', '[':
+		node.kind = c
+		err := p.parseBlock(node)
+		if err != nil {
+			return nil, err
+		}
+
+	case '"':
+		p.pos = skipString(p.content, p.pos)
+
+	default:
+		end := p.pos
+		for end < len(p.content) && !bytes.ContainsAny(p.content[end:end+1], " \t\r\n,]}/") {
+			end++
+		}
+		if end == p.pos {
+			return nil, p.errorf("unexpected character %q", c)
+		}
+		p.pos = end
+	}
+
+	node.end = p.pos
+	return node, nil
+}
+
+func (p *jsoncParser) parseBlock(node *jsoncNode) error {
+	closing := byte('}')
+	if node.kind == '[' {
+		closing = ']'
+	}
+
+	p.pos++ // apertura
+
+	for {
+		p.pos = skipBlanks(p.content, p.pos)
+		if p.pos >= len(p.content) {
+			return p.errorf("unexpected end of data")
+		}
+		if p.content[p.pos] == closing {
+			p.pos++
+			return nil
+		}
+
+		var member jsoncMember
+
+		if node.kind == '{' {
+			if p.content[p.pos] != '"' {
+				return p.errorf("expected object key")
+			}
+			member.start = p.pos
+			p.pos = skipString(p.content, p.pos)
+
+			err := json.Unmarshal(p.content[member.start:p.pos], &member.key)
+			if err != nil {
+				return p.errorf("invalid object key")
+			}
+
+			p.pos = skipBlanks(p.content, p.pos)
+			if p.pos >= len(p.content) || p.content[p.pos] != ':' {
+				return p.errorf("expected ':' after object key")
+			}
+			p.pos++
+		}
+
+		value, err := p.parseValue()
+		if err != nil {
+			return err
+		}
+
+		member.value = value
+		member.commaEnd = value.end
+
+		p.pos = skipBlanks(p.content, p.pos)
+		if p.pos < len(p.content) && p.content[p.pos] == ',' {
+			p.pos++
+			member.commaEnd = p.pos
+			member.hasComma = true
+		} else if p.pos < len(p.content) && p.content[p.pos] != closing {
+			return p.errorf("expected ',' or '%c'", closing)
+		}
+
+		if node.kind == '{' {
+			node.members = append(node.members, member)
+		}
+	}
+}
+
+// modifica al testo originale
+type jsoncEdit struct {
+	start, end int
+	text       string
+}
+
+type jsoncPatcher struct {
+	orig    []byte
+	data    []byte
+	indent  string // unità di indentazione
+	edits   []jsoncEdit
+	newline string
+}
+
+// patchJSONC - applica al documento originale (JSONC) i valori di data (JSON),
+// modificando soltanto i valori cambiati. Le chiavi nuove vengono aggiunte in coda
+// all'oggetto che le contiene, quelle non più presenti vengono rimosse.
+func patchJSONC(orig []byte, data []byte) ([]byte, error) {
+	origRoot, err := parseJSONC(orig)
+	if err != nil {
+		return nil, err
+	}
+
+	dataRoot, err := parseJSONC(data)
+	if err != nil {
+		return nil, err
+	}
+
+	p := jsoncPatcher{
+		orig:    orig,
+		data:    data,
+		indent:  "\t",
+		newline: "\n",
+	}
+	if bytes.Contains(orig, []byte("\r\n")) {
+		p.newline = "\r\n"
+	}
+	if origRoot.kind == '{' && len(origRoot.members) > 0 {
+		if indent := p.lineIndent(origRoot.members[0].start); indent != "" {
+			p.indent = indent
+		}
+	}
+
+	err = p.patchNode(origRoot, dataRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	// applica le modifiche dalla fine, in modo che le posizioni restino valide
+	sort.SliceStable(p.edits, func(i, j int) bool {
+		return p.edits[i].start > p.edits[j].start
+	})
+
+	out := append([]byte{}, orig...)
+	for _, e := range p.edits {
+		out = append(out[:e.start], append([]byte(e.text), out[e.end:]...)...)
+	}
+
+	return out, nil
+}
+
+func (p *jsoncPatcher) patchNode(orig *jsoncNode, data *jsoncNode) error {
+	if orig.kind == '{' && data.kind == '{' {
+		return p.patchObject(orig, data)
+	}
+
+	origValue, err := compactJSON(StripJSONC(p.orig[orig.start:orig.end]))
+	if err != nil {
+		return err
+	}
+	dataValue, err := compactJSON(p.data[data.start:data.end])
+	if err != nil {
+		return err
+	}
+
+	if origValue != dataValue {
+		p.edits = append(p.edits, jsoncEdit{
+			start: orig.start,
+			end:   orig.end,
+			text:  p.formatValue(data, p.lineIndent(orig.start)),
+		})
+	}
+
+	return nil
+}
+
+func (p *jsoncPatcher) patchObject(orig *jsoncNode, data *jsoncNode) error {
+	// stessa regola di encoding/json: le chiavi non distinguono maiuscole e minuscole;
+	// nel file resta la grafia dell'utente
+	dataMember := func(key string) *jsoncMember {
+		var found *jsoncMember
+		for i := range data.members {
+			if data.members[i].key == key {
+				return &data.members[i]
+			}
+			if found == nil && strings.EqualFold(data.members[i].key, key) {
+				found = &data.members[i]
+			}
+		}
+		return found
+	}
+
+	matched := make(map[*jsoncMember]bool)
+	var lastKept *jsoncMember
+
+	for i := range orig.members {
+		m := &orig.members[i]
+
+		dm := dataMember(m.key)
+		if dm == nil {
+			p.removeMember(m)
+			continue
+		}
+		matched[dm] = true
+
+		err := p.patchNode(m.value, dm.value)
+		if err != nil {
+			return err
+		}
+		lastKept = m
+	}
+
+	var added []*jsoncMember
+	for i := range data.members {
+		if !matched[&data.members[i]] {
+			added = append(added, &data.members[i])
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	// oggetto su una sola riga: i nuovi membri vengono aggiunti sulla stessa riga
+	inline := len(orig.members) > 0 &&
+		!bytes.Contains(p.orig[orig.start:orig.members[0].start], []byte("\n"))
+
+	var memberIndent string
+	if len(orig.members) > 0 {
+		memberIndent = p.lineIndent(orig.members[0].start)
+	} else {
+		memberIndent = p.lineIndent(orig.start) + p.indent
+	}
+
+	var text strings.Builder
+	for i, m := range added {
+		if i > 0 {
+			text.WriteString(",")
+		}
+		key, _ := json.Marshal(m.key)
+		if inline {
+			text.WriteString(" ")
+			text.Write(key)
+			text.WriteString(": ")
+			text.Write(p.data[m.value.start:m.value.end])
+		} else {
+			text.WriteString(p.newline + memberIndent)
+			text.Write(key)
+			text.WriteString(": " + p.formatValue(m.value, memberIndent))
+		}
+	}
+
+	if lastKept == nil {
+		// oggetto vuoto (o svuotato): inserisce subito dopo l'apertura e l'eventuale
+		// commento; l'a capo prima della chiusura va aggiunto solo se manca
+		insertAt := p.trailingComment(orig.start + 1)
+		next := insertAt
+		for next < len(p.orig) && (p.orig[next] == ' ' || p.orig[next] == '\t' || p.orig[next] == '\r') {
+			next++
+		}
+		if !inline && (next >= len(p.orig) || p.orig[next] != '\n') {
+			text.WriteString(p.newline + p.lineIndent(orig.start))
+		}
+		p.edits = append(p.edits, jsoncEdit{start: insertAt, end: insertAt, text: text.String()})
+		return nil
+	}
+
+	if lastKept.hasComma && lastKept == &orig.members[len(orig.members)-1] {
+		// mantiene la virgola finale anche dopo i nuovi membri
+		text.WriteString(",")
+	}
+
+	// inserisce dopo l'eventuale commento sulla stessa riga
+	insertAt := p.trailingComment(lastKept.commaEnd)
+
+	if !lastKept.hasComma {
+		if insertAt == lastKept.commaEnd {
+			p.edits = append(p.edits, jsoncEdit{start: insertAt, end: insertAt, text: "," + text.String()})
+			return nil
+		}
+		p.edits = append(p.edits, jsoncEdit{start: lastKept.commaEnd, end: lastKept.commaEnd, text: ","})
+	}
+
+	p.edits = append(p.edits, jsoncEdit{start: insertAt, end: insertAt, text: text.String()})
+	return nil
+}
+
+// rimuove un membro, con l'eventuale commento sulla stessa riga,
+// compresa la riga se non contiene altro
+func (p *jsoncPatcher) removeMember(m *jsoncMember) {
+	start, end := m.start, p.trailingComment(m.commaEnd)
+
+	lineStart := start
+	for lineStart > 0 && (p.orig[lineStart-1] == ' ' || p.orig[lineStart-1] == '\t') {
+		lineStart--
+	}
+	lineEnd := end
+	for lineEnd < len(p.orig) && (p.orig[lineEnd] == ' ' || p.orig[lineEnd] == '\t' || p.orig[lineEnd] == '\r') {
+		lineEnd++
+	}
+
+	if (lineStart == 0 || p.orig[lineStart-1] == '\n') && lineEnd < len(p.orig) && p.orig[lineEnd] == '\n' {
+		start, end = lineStart, lineEnd+1
+	}
+
+	p.edits = append(p.edits, jsoncEdit{start: start, end: end})
+}
+
+// ritorna la fine dell'eventuale commento // che segue pos sulla stessa riga
+// (a capo escluso), altrimenti pos
+func (p *jsoncPatcher) trailingComment(pos int) int {
+	i := pos
+	for i < len(p.orig) && (p.orig[i] == ' ' || p.orig[i] == '\t') {
+		i++
+	}
+	if !bytes.HasPrefix(p.orig[i:], []byte("//")) {
+		return pos
+	}
+
+	i = skipComment(p.orig, i)
+	if p.orig[i-1] == '\r' {
+		i--
+	}
+	return i
+}
+
+// formatta un valore di data indentandolo a partire da prefix
+func (p *jsoncPatcher) formatValue(node *jsoncNode, prefix string) string {
+	raw := p.data[node.start:node.end]
+	if node.kind == 0 {
+		return string(raw)
+	}
+
+	var buf bytes.Buffer
+	err := json.Indent(&buf, raw, prefix, p.indent)
+	if err != nil {
+		return string(raw)
+	}
+
+	if p.newline != "\n" {
+		return strings.ReplaceAll(buf.String(), "\n", p.newline)
+	}
+	return buf.String()
+}
+
+// ritorna l'indentazione della riga che contiene pos
+func (p *jsoncPatcher) lineIndent(pos int) string {
+	lineStart := bytes.LastIndexByte(p.orig[:pos], '\n') + 1
+
+	end := lineStart
+	for end < pos && (p.orig[end] == ' ' || p.orig[end] == '\t') {
+		end++
+	}
+
+	return string(p.orig[lineStart:end])
+}
+
+func compactJSON(raw []byte) (string, error) {
+	var buf bytes.Buffer
+
+	err := json.Compact(&buf, raw)
+	if err != nil {
+		return "", errors.New("jsonc: " + err.Error())
+	}
+
+	return buf.String(), nil
+}
+
+// setJSONMember - imposta il valore (JSON) di una chiave di un oggetto JSON;
+// se value è nil la chiave viene rimossa
+func setJSONMember(obj []byte, key string, value []byte) ([]byte, error) {
+	root, err := parseJSONC(obj)
+	if err != nil {
+		return nil, err
+	}
+	if root.kind != '{' {
+		return nil, errors.New("jsonc: not an object")
+	}
+
+	edit := func(start, end int, text []byte) []byte {
+		out := append([]byte{}, obj[:start]...)
+		out = append(out, text...)
+		return append(out, obj[end:]...)
+	}
+
+	for i, m := range root.members {
+		if m.key != key {
+			continue
+		}
+
+		if value != nil {
+			return edit(m.value.start, m.value.end, value), nil
+		}
+
+		if i == len(root.members)-1 && i > 0 {
+			// ultimo membro: rimuove anche la virgola del precedente
+			return edit(root.members[i-1].value.end, m.commaEnd, nil), nil
+		}
+		return edit(m.start, m.commaEnd, nil), nil
+	}
+
+	if value == nil {
+		return obj, nil
+	}
+
+	// chiave assente: aggiunta in coda
+	k, _ := json.Marshal(key)
+	member := append(append(k, ':'), value...)
+
+	closing := root.end - 1
+	if len(root.members) > 0 {
+		member = append([]byte{','}, member...)
+		closing = root.members[len(root.members)-1].value.end
+	}
+
+	return edit(closing, closing, member), nil
+}
@@ -0,0 +1,145 @@
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/marcozaccari/AssistantBot/internal/fileutil"
+)
+
+// MigrationFunc - converte i dati JSON di una sezione dalla versione N alla N+1
+type MigrationFunc func(data json.RawMessage) (json.RawMessage, error)
+
+// chiave riservata, all'interno di ogni sezione, con la versione dello schema
+const schemaVersionKey = "_version"
+
+// RegisterMigration - registra la migrazione di una sezione (vuota se Data non è
+// una mappa) dalla versione from alla from+1. La versione attuale dello schema della
+// sezione è quella successiva all'ultima migrazione registrata; i file privi di
+// versione sono considerati alla versione 0.
+// Le migrazioni vengono eseguite automaticamente da LoadSettings, che conserva una
+// copia del file originale e salva subito quello migrato.
+func (set *Settings) RegisterMigration(section string, from int, migrate MigrationFunc) {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+
+	if set.migrations == nil {
+		set.migrations = make(map[string]map[int]MigrationFunc)
+	}
+	if set.migrations[section] == nil {
+		set.migrations[section] = make(map[int]MigrationFunc)
+	}
+
+	set.migrations[section][from] = migrate
+}
+
+// SchemaVersion - versione attuale dello schema della sezione
+func (set *Settings) SchemaVersion(section string) int {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+
+	return set.schemaVersion(section)
+}
+
+func (set *Settings) schemaVersion(section string) int {
+	version := 0
+	for from := range set.migrations[section] {
+		if from+1 > version {
+			version = from + 1
+		}
+	}
+
+	return version
+}
+
+// porta i dati della sezione alla versione attuale dello schema, rimuovendo la chiave
+// della versione; ritorna true se è stata eseguita almeno una migrazione
+func (set *Settings) migrate(section string, raw json.RawMessage) (json.RawMessage, bool, error) {
+	if len(raw) == 0 {
+		return raw, false, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(raw, &fields) != nil {
+		// non è un oggetto: l'errore verrà riportato dalla deserializzazione
+		return raw, false, nil
+	}
+
+	version := 0
+	if v, ok := fields[schemaVersionKey]; ok {
+		err := json.Unmarshal(v, &version)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid %s", schemaVersionKey)
+		}
+
+		raw, err = setJSONMember(raw, schemaVersionKey, nil)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	current := set.schemaVersion(section)
+	if version > current {
+		return nil, false, fmt.Errorf("schema version %d is newer than supported (%d)", version, current)
+	}
+
+	for v := version; v < current; v++ {
+		migrate, ok := set.migrations[section][v]
+		if !ok {
+			return nil, false, fmt.Errorf("missing migration from schema version %d", v)
+		}
+
+		var err error
+		raw, err = migrate(raw)
+		if err != nil {
+			return nil, false, fmt.Errorf("migration from schema version %d: %v", v, err)
+		}
+
+		set.log().Info("Settings migrated", "section", section, "from", v, "to", v+1)
+	}
+
+	return raw, version < current, nil
+}
+
+// aggiunge ai dati serializzati la versione dello schema delle sezioni versionate
+func (set *Settings) addSchemaVersions(data []byte) ([]byte, error) {
+	if len(set.migrations) == 0 {
+		return data, nil
+	}
+
+	if _, isMap := set.Data.(map[string]interface{}); !isMap {
+		return setJSONMember(data, schemaVersionKey, []byte(strconv.Itoa(set.schemaVersion(""))))
+	}
+
+	var sections map[string]json.RawMessage
+	err := json.Unmarshal(data, &sections)
+	if err != nil {
+		return nil, err
+	}
+
+	for section := range set.migrations {
+		raw, ok := sections[section]
+		if !ok {
+			continue
+		}
+
+		version := []byte(strconv.Itoa(set.schemaVersion(section)))
+		sections[section], err = setJSONMember(raw, schemaVersionKey, version)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(sections)
+}
+
+// conserva il file originale prima di sovrascriverlo con quello migrato
+func (set *Settings) backupBeforeMigration(content []byte) error {
+	name := set.filename + ".pre-migration-" + time.Now().Format("20060102T150405") + ".bak"
+
+	set.log().Warn("Settings migrated, original file saved", "file", name)
+
+	return fileutil.WriteAtomic(name, content, fileMode)
+}
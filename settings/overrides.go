@@ -0,0 +1,247 @@
+package settings
+
+// Sovrascrittura dei campi tramite variabili d'ambiente e parametri da riga di comando.
+// I valori sovrascritti non vengono mai salvati nel file: al salvataggio viene
+// ripristinato il valore originariamente presente nel file.
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"strings"
+)
+
+// valore sovrascritto di un campo
+type override struct {
+	section string
+	field   string
+	value   string
+}
+
+// valore originale di un campo sovrascritto
+type overriddenField struct {
+	key string          // nome del campo nella serializzazione
+	raw json.RawMessage // valore presente nel file; nil se assente
+}
+
+// SetOverride - sovrascrive un campo di una sezione (vuota se Data non è una mappa).
+// Ha la precedenza sulle variabili d'ambiente; viene applicato al caricamento.
+func (set *Settings) SetOverride(section string, field string, value string) {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+
+	set.overrides = append(set.overrides, override{section, field, value})
+}
+
+// OverrideFlag - ritorna un flag.Value che accetta sovrascritture nella forma
+// "sezione.campo=valore" (oppure "campo=valore" se Data non è una mappa).
+// Es. flag.Var(set.OverrideFlag(), "set", "override a setting (section.field=value)")
+func (set *Settings) OverrideFlag() flag.Value {
+	return &overrideFlag{set: set}
+}
+
+type overrideFlag struct {
+	set    *Settings
+	values []string
+}
+
+func (f *overrideFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(f.values, ",")
+}
+
+func (f *overrideFlag) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return errors.New("expected section.field=value")
+	}
+
+	key, value := s[:i], s[i+1:]
+
+	var section string
+	if j := strings.Index(key, "."); j >= 0 {
+		section, key = key[:j], key[j+1:]
+	}
+
+	f.set.SetOverride(section, key, value)
+	f.values = append(f.values, s)
+
+	return nil
+}
+
+// ritorna le sovrascritture della sezione: prima le variabili d'ambiente, poi
+// quelle impostate esplicitamente, in modo che queste ultime prevalgano
+func (set *Settings) sectionOverrides(section string) []override {
+	var list []override
+
+	if set.EnvPrefix != "" {
+		for _, env := range os.Environ() {
+			i := strings.Index(env, "=")
+			if i < 0 {
+				continue
+			}
+
+			envSection, field, ok := set.envOverride(env[:i])
+			if !ok || envSection != section {
+				continue
+			}
+
+			list = append(list, override{section, field, env[i+1:]})
+		}
+	}
+
+	for _, o := range set.overrides {
+		if strings.EqualFold(o.section, section) {
+			list = append(list, o)
+		}
+	}
+
+	return list
+}
+
+// sezione e campo a cui si riferisce la variabile d'ambiente. I nomi delle sezioni
+// possono contenere "_": vale la sezione dal nome più lungo, in modo che ad es.
+// PREFISSO_MY_SCOPE_CAMPO appartenga alla sezione "My_scope" e non a "My".
+func (set *Settings) envOverride(name string) (section string, field string, ok bool) {
+	name = strings.ToUpper(name)
+	prefix := strings.ToUpper(set.EnvPrefix) + "_"
+	if !strings.HasPrefix(name, prefix) {
+		return "", "", false
+	}
+	name = name[len(prefix):]
+
+	sections, isMap := set.sections()
+	if !isMap {
+		return "", name, name != ""
+	}
+
+	for key := range sections {
+		sectionPrefix := strings.ToUpper(key) + "_"
+		if !strings.HasPrefix(name, sectionPrefix) || len(name) == len(sectionPrefix) {
+			continue
+		}
+
+		if !ok || len(key) > len(section) {
+			section, field, ok = key, name[len(sectionPrefix):], true
+		}
+	}
+
+	return section, field, ok
+}
+
+// applica le sovrascritture alla sezione appena deserializzata,
+// memorizzando i valori originali presenti nel file
+func (set *Settings) applyOverrides(section string, raw json.RawMessage, data interface{},
+	overridden map[string]map[string]overriddenField) error {
+
+	list := set.sectionOverrides(section)
+	if len(list) == 0 {
+		return nil
+	}
+
+	var fileFields map[string]json.RawMessage
+	if len(raw) > 0 {
+		json.Unmarshal(raw, &fileFields)
+	}
+
+	var errs Errors
+
+	for _, o := range list {
+		_, key, ok := FieldByName(data, o.field)
+		if !ok {
+			if set.Strict {
+				errs = append(errs, &FieldError{Section: section, Field: o.field, Message: "unknown field (override)"})
+			}
+			continue
+		}
+
+		err := SetField(data, key, o.value)
+		if err != nil {
+			errs = append(errs, &FieldError{Section: section, Field: key, Message: err.Error()})
+			continue
+		}
+
+		original := overriddenField{key: key}
+		for k, v := range fileFields {
+			// stessa regola di encoding/json
+			if strings.EqualFold(k, key) {
+				original.raw = v
+				break
+			}
+		}
+
+		if overridden[section] == nil {
+			overridden[section] = make(map[string]overriddenField)
+		}
+		overridden[section][key] = original
+
+		set.log().Info("Setting overridden", "section", section, "field", key)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// IsOverridden - true se il campo della sezione è sovrascritto da variabile d'ambiente o flag
+func (set *Settings) IsOverridden(section string, field string) bool {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+
+	for key := range set.overridden[section] {
+		if strings.EqualFold(key, field) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ripristina nei dati serializzati i valori originali dei campi sovrascritti
+func (set *Settings) restoreOverridden(data []byte) ([]byte, error) {
+	if len(set.overridden) == 0 {
+		return data, nil
+	}
+
+	if _, isMap := set.Data.(map[string]interface{}); !isMap {
+		return restoreFields(data, set.overridden[""])
+	}
+
+	var sections map[string]json.RawMessage
+	err := json.Unmarshal(data, &sections)
+	if err != nil {
+		return nil, err
+	}
+
+	for section, fields := range set.overridden {
+		raw, ok := sections[section]
+		if !ok {
+			continue
+		}
+
+		sections[section], err = restoreFields(raw, fields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(sections)
+}
+
+func restoreFields(data []byte, fields map[string]overriddenField) ([]byte, error) {
+	var err error
+
+	for _, f := range fields {
+		data, err = setJSONMember(data, f.key, f.raw)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
@@ -0,0 +1,140 @@
+package settings
+
+// Risoluzione del percorso del file delle impostazioni.
+// Un nome relativo viene cercato nell'ordine in SearchPaths (di default: directory
+// di lavoro, directory di configurazione dell'utente, directory dell'eseguibile);
+// se non esiste in nessuna e DefaultContent è impostato, viene creato nella prima.
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/marcozaccari/AssistantBot/internal/fileutil"
+)
+
+// nome del file se non specificato
+const defaultFilename = "settings.json"
+
+// DefaultSearchPaths - directory in cui cercare un file delle impostazioni relativo:
+// directory di lavoro, directory di configurazione dell'utente
+// ($XDG_CONFIG_HOME/appName, solo se appName non è vuoto), directory dell'eseguibile
+func DefaultSearchPaths(appName string) []string {
+	var dirs []string
+
+	if wd, err := os.Getwd(); err == nil {
+		dirs = append(dirs, wd)
+	}
+
+	if appName != "" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			dirs = append(dirs, filepath.Join(dir, appName))
+		}
+	}
+
+	if ex, err := os.Executable(); err == nil {
+		dirs = append(dirs, filepath.Dir(ex))
+	}
+
+	return dirs
+}
+
+// directory di ricerca effettive
+func (set *Settings) searchPaths() []string {
+	if set.SearchPaths != nil {
+		return set.SearchPaths
+	}
+
+	return DefaultSearchPaths(set.AppName)
+}
+
+// risolve il percorso del file al primo accesso, in modo che SearchPaths,
+// AppName e DefaultContent possano essere impostati dopo New.
+// Va invocata con set.lock acquisito.
+func (set *Settings) resolveFilename() error {
+	if set.resolved {
+		return nil
+	}
+
+	if filepath.IsAbs(set.filename) {
+		set.resolved = true
+		return nil
+	}
+
+	dirs := set.searchPaths()
+
+	for _, dir := range dirs {
+		filename := filepath.Join(dir, set.filename)
+
+		if _, err := os.Stat(filename); err == nil {
+			set.setResolved(filename)
+			return nil
+		}
+	}
+
+	if len(dirs) == 0 {
+		// nessuna directory disponibile: relativo alla directory di lavoro
+		set.resolved = true
+		return nil
+	}
+
+	if set.DefaultContent == nil {
+		// il file non esiste: LoadSettings riporterà l'errore sul primo percorso
+		set.setResolved(filepath.Join(dirs[0], set.filename))
+		return nil
+	}
+
+	var err error
+	for _, dir := range dirs {
+		filename := filepath.Join(dir, set.filename)
+
+		err = createDefault(filename, set.DefaultContent)
+		if err == nil {
+			set.log().Warn("Settings file created from default", "file", filename)
+			set.setResolved(filename)
+			return nil
+		}
+	}
+
+	return err
+}
+
+func (set *Settings) setResolved(filename string) {
+	set.statusLock.Lock()
+	set.filename = filename
+	set.statusLock.Unlock()
+	set.resolved = true
+
+	set.log().Info("Settings filename", "file", set.filename)
+}
+
+func createDefault(filename string, content []byte) error {
+	err := os.MkdirAll(filepath.Dir(filename), 0700)
+	if err != nil {
+		return err
+	}
+
+	// non sovrascrive un file creato nel frattempo
+	if _, err := os.Stat(filename); err == nil {
+		return nil
+	}
+
+	return fileutil.WriteAtomic(filename, content, fileMode)
+}
+
+// legge il file memorizzandone lo stato, usato per rilevare le modifiche esterne
+func (set *Settings) readFile() ([]byte, error) {
+	err := set.resolveFilename()
+	if err != nil {
+		return nil, err
+	}
+
+	fileContent, err := ioutil.ReadFile(set.filename)
+	if err != nil {
+		return nil, err
+	}
+
+	set.updateFileStat()
+
+	return fileContent, nil
+}
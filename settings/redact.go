@@ -0,0 +1,174 @@
+package settings
+
+// Mascheramento dei campi riservati (es. token) nelle visualizzazioni della configurazione.
+// Un campo è riservato se dichiarato con il tag `secret:"true"`.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// RedactedMask - valore mostrato al posto dei campi riservati non vuoti
+const RedactedMask = "********"
+
+// IsSecret - true se il campo è dichiarato riservato (tag `secret:"true"`)
+func IsSecret(f reflect.StructField) bool {
+	return f.Tag.Get("secret") == "true"
+}
+
+// Redact - serializza data in JSON mascherando i campi riservati, anche
+// se contenuti in sotto-strutture, mappe o slice. I campi vuoti restano
+// visibili, in modo da poter distinguere quelli non impostati.
+func Redact(data interface{}) ([]byte, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return redactValue(reflect.ValueOf(data), b)
+}
+
+func redactValue(v reflect.Value, b []byte) ([]byte, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return b, nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return redactStruct(v, b)
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return b, nil
+		}
+
+		var members map[string]json.RawMessage
+		if json.Unmarshal(b, &members) != nil {
+			return b, nil
+		}
+
+		var err error
+		for _, key := range v.MapKeys() {
+			raw, ok := members[key.String()]
+			if !ok {
+				continue
+			}
+
+			members[key.String()], err = redactValue(v.MapIndex(key), raw)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return json.Marshal(members)
+
+	case reflect.Slice, reflect.Array:
+		var items []json.RawMessage
+		if json.Unmarshal(b, &items) != nil || len(items) != v.Len() {
+			return b, nil
+		}
+
+		var err error
+		for i := range items {
+			items[i], err = redactValue(v.Index(i), items[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return json.Marshal(items)
+	}
+
+	return b, nil
+}
+
+func redactStruct(v reflect.Value, b []byte) ([]byte, error) {
+	var members map[string]json.RawMessage
+	if json.Unmarshal(b, &members) != nil {
+		return b, nil
+	}
+
+	var err error
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		if f.Anonymous && f.Tag.Get("json") == "" {
+			// i campi delle strutture incluse sono allo stesso livello
+			b, err = redactValue(v.Field(i), b)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		key := jsonFieldName(f)
+		raw, ok := members[key]
+		if key == "-" || !ok {
+			continue
+		}
+
+		value := raw
+		if IsSecret(f) {
+			if !v.Field(i).IsZero() {
+				value, _ = json.Marshal(RedactedMask)
+			}
+		} else {
+			value, err = redactValue(v.Field(i), raw)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if !bytes.Equal(value, raw) {
+			b, err = setJSONMember(b, key, value)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return b, nil
+}
+
+// Dump - ritorna in JSON indentato i valori effettivi (sovrascritture comprese)
+// della sezione, o di tutte le sezioni se section è vuota, con i campi riservati mascherati
+func (set *Settings) Dump(section string) ([]byte, error) {
+	if set.Data == nil {
+		return nil, errors.New("settings data struct not set")
+	}
+
+	data := set.Data
+
+	if _, isMap := set.Data.(map[string]interface{}); isMap && section != "" {
+		var ok bool
+		_, data, ok = set.section(section)
+		if !ok {
+			return nil, errors.New("unknown section " + section)
+		}
+	}
+
+	set.dataLock.RLock()
+	b, err := Redact(data)
+	set.dataLock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = json.Indent(&buf, b, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,175 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Reload - ricarica le impostazioni dal file. Le nuove impostazioni vengono
+// applicate solo se tutte le sezioni sono valide; in caso contrario restano
+// quelle attuali. Ritorna le sezioni modificate, notificate tramite OnChange.
+// Come in LoadSettings, il file migrato ad una nuova versione dello schema viene salvato.
+func (set *Settings) Reload() ([]string, error) {
+	if set.Data == nil {
+		return nil, errors.New("settings data struct not set")
+	}
+
+	set.lock.Lock()
+
+	set.log().Info("Reload settings")
+
+	fileContent, err := set.readFile()
+	if err != nil {
+		set.lock.Unlock()
+		return nil, err
+	}
+
+	// a differenza del primo caricamento, le sezioni ripartono da zero
+	// in modo che i campi rimossi dal file tornino ai default
+	result, err := set.decode(fileContent, false)
+	if err != nil {
+		set.lock.Unlock()
+		return nil, err
+	}
+
+	sections, _ := set.sections()
+
+	type change struct {
+		section  string
+		old, new interface{}
+	}
+	changes := []change{}
+
+	set.dataLock.RLock()
+
+	for key, data := range result.sections {
+		current := reflect.ValueOf(sections[key])
+
+		oldJSON, _ := json.Marshal(current.Interface())
+		newJSON, _ := json.Marshal(data)
+		if string(oldJSON) == string(newJSON) {
+			continue
+		}
+
+		old := copyData(current)
+
+		changes = append(changes, change{key, old.Interface(), current.Interface()})
+	}
+
+	set.dataLock.RUnlock()
+
+	set.apply(result)
+	onChange := set.OnChange
+
+	if result.migrated {
+		// le impostazioni ricaricate restano comunque applicate
+		err = set.saveMigrated(fileContent)
+		if err != nil {
+			set.log().Error("Cannot save migrated settings", "err", err)
+		}
+	}
+
+	set.lock.Unlock()
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].section < changes[j].section
+	})
+
+	changed := make([]string, len(changes))
+	for i, c := range changes {
+		changed[i] = c.section
+
+		if onChange != nil {
+			onChange(c.section, c.old, c.new)
+		}
+	}
+
+	if len(changed) > 0 {
+		set.log().Info("Settings changed", "sections", strings.Join(changed, ","))
+	}
+
+	return changed, nil
+}
+
+// Watch - controlla periodicamente se il file è stato modificato esternamente
+// (data di modifica o dimensione) e in tal caso lo ricarica con Reload.
+func (set *Settings) Watch(interval time.Duration) {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+
+	if set.stopWatch != nil {
+		return
+	}
+
+	stop := make(chan struct{})
+	set.stopWatch = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			if !set.fileChanged() {
+				continue
+			}
+
+			_, err := set.Reload()
+			if err != nil {
+				// il file non viene riletto finchè non cambia nuovamente
+				set.log().Error("Cannot reload settings", "err", err)
+			}
+		}
+	}()
+}
+
+// StopWatch - interrompe il controllo avviato da Watch
+func (set *Settings) StopWatch() {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+
+	if set.stopWatch != nil {
+		close(set.stopWatch)
+		set.stopWatch = nil
+	}
+}
+
+// memorizza data di modifica e dimensione attuali del file
+func (set *Settings) updateFileStat() {
+	info, err := os.Stat(set.filename)
+	if err != nil {
+		return
+	}
+
+	set.fileModTime = info.ModTime()
+	set.fileSize = info.Size()
+}
+
+func (set *Settings) fileChanged() bool {
+	info, err := os.Stat(set.Filename())
+	if err != nil {
+		return false
+	}
+
+	set.lock.Lock()
+	defer set.lock.Unlock()
+
+	changed := !info.ModTime().Equal(set.fileModTime) || info.Size() != set.fileSize
+	if changed {
+		// in caso di errore nel reload evita di riprovare ad ogni controllo
+		set.fileModTime = info.ModTime()
+		set.fileSize = info.Size()
+	}
+
+	return changed
+}
@@ -2,49 +2,121 @@
 package settings
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
-	"log"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/marcozaccari/AssistantBot/internal/fileutil"
+	"github.com/marcozaccari/AssistantBot/logger"
 )
 
 // Settings - struttura oggetto direttamente utilizzabile.
 type Settings struct {
-	verbose  bool
 	filename string
+	resolved bool // filename è stato risolto in un percorso assoluto (vedi paths.go)
+
+	// SearchPaths - directory in cui cercare il file se il nome è relativo, in ordine;
+	// se nil viene usato DefaultSearchPaths(AppName)
+	SearchPaths []string
+
+	// AppName - nome della sottodirectory nella directory di configurazione
+	// dell'utente (es. ~/.config/AppName); se vuoto questa non viene considerata
+	AppName string
+
+	// DefaultContent - se impostato e il file non viene trovato, il file viene
+	// creato con questo contenuto nella prima directory di ricerca scrivibile
+	DefaultContent []byte
+
+	// Strict - rifiuta le chiavi sconosciute (campi e sezioni non registrate)
+	Strict bool
+
+	// Codec - formato del file; di default scelto in base all'estensione
+	Codec Codec
+
+	// Backups - numero di copie di backup mantenute ad ogni salvataggio (0 = nessuna);
+	// se il file risulta corrotto LoadSettings carica la copia valida più recente
+	Backups int
+
+	// EnvPrefix - prefisso delle variabili d'ambiente che sovrascrivono i campi
+	// (PREFISSO_SEZIONE_CAMPO, es. ASSISTANTBOT_BOT_SECURETOKEN); vuoto = disattivate
+	EnvPrefix string
+
+	overrides  []override
+	overridden map[string]map[string]overriddenField // sezione -> campo
 
-	lock      sync.Mutex
-	timerSave *time.Timer
+	migrations map[string]map[int]MigrationFunc // sezione -> versione di partenza
+
+	lock     sync.Mutex   // serializza le operazioni sul file
+	dataLock sync.RWMutex // protegge i dati (vedi Update e View)
+
+	timerLock   sync.Mutex
+	timerSave   *time.Timer
+	savePending bool // protetto da timerLock
+
+	statusLock  sync.Mutex // protegge anche le modifiche di filename
+	lastSave    time.Time
+	lastSaveErr error
+
+	// Logger - destinazione dei log (compatibile con *slog.Logger); se nil vengono
+	// scritti su stderr, a partire dal livello Info se verbose, altrimenti Warn
+	Logger        logger.Logger
+	defaultLogger logger.Logger
+
+	// OnChange - se impostata viene invocata da Reload per ogni sezione modificata;
+	// old è una copia dei valori precedenti, new la struttura registrata
+	OnChange func(section string, old interface{}, new interface{})
+
+	fileModTime time.Time
+	fileSize    int64
+	stopWatch   chan struct{}
 
 	Data interface{} // Punta ad una struttura dati custom
 }
 
 func (set *Settings) init(filename string, data interface{}, verbose bool) error {
-	if filename[0] != '/' {
-		ex, err := os.Executable()
-		if err != nil {
-			return err
-		}
-		set.filename = filepath.Dir(ex) + "/" + filename
-	} else {
-		set.filename = filename
+	if filename == "" {
+		filename = defaultFilename
 	}
+	set.filename = filepath.Clean(filename)
 
 	set.Data = data
+	set.Codec = CodecFor(set.filename)
+	set.Backups = defaultBackups
 
-	set.verbose = verbose
-
+	level := logger.LevelWarn
 	if verbose {
-		log.Println("Settings filename", set.filename)
+		level = logger.LevelInfo
 	}
+	set.defaultLogger = logger.Default(level)
 
 	return nil
 }
 
+func (set *Settings) log() logger.Logger {
+	if set.Logger != nil {
+		return set.Logger
+	}
+
+	return set.defaultLogger
+}
+
+// Filename - path del file delle impostazioni; completo dopo il primo
+// caricamento o salvataggio (vedi SearchPaths). Può essere invocata anche
+// dalle migrazioni, durante il caricamento.
+func (set *Settings) Filename() string {
+	set.statusLock.Lock()
+	defer set.statusLock.Unlock()
+
+	return set.filename
+}
+
 // LoadSettings - carica le impostazioni
 func (set *Settings) LoadSettings() error {
 	if set.Data == nil {
@@ -54,48 +126,200 @@ func (set *Settings) LoadSettings() error {
 	set.lock.Lock()
 	defer set.lock.Unlock()
 
-	if set.verbose {
-		log.Println("Load settings")
+	set.log().Info("Load settings")
+
+	fileContent, err := set.readFile()
+	if err != nil {
+		if !os.IsNotExist(err) && set.recoverFromBackup() {
+			set.log().Warn("Cannot read settings file", "err", err)
+			return nil
+		}
+		return err
+	}
+
+	// il primo caricamento parte dai valori attuali delle strutture,
+	// in modo da mantenere quelli eventualmente impostati dal codice
+	result, err := set.decode(fileContent, true)
+	if err != nil {
+		// solo i file corrotti vengono recuperati dal backup; gli errori di
+		// validazione (Errors) vanno corretti dall'utente
+		if _, invalid := err.(Errors); !invalid && set.recoverFromBackup() {
+			set.log().Warn("Corrupted settings file", "err", err)
+			return nil
+		}
+		return err
+	}
+
+	set.apply(result)
+
+	if result.migrated {
+		return set.saveMigrated(fileContent)
 	}
 
-	fileContent, err := ioutil.ReadFile(set.filename)
+	return nil
+}
+
+// salva subito il file migrato, dopo averne conservato una copia (fileContent)
+func (set *Settings) saveMigrated(fileContent []byte) error {
+	err := set.backupBeforeMigration(fileContent)
 	if err != nil {
 		return err
 	}
 
-	data, isMap := set.Data.(map[string]interface{})
+	return set.save()
+}
+
+// risultato della deserializzazione, applicato soltanto se valido
+type decoded struct {
+	sections   map[string]interface{} // nuove istanze delle sezioni
+	overridden map[string]map[string]overriddenField
+	migrated   bool // almeno una sezione è stata migrata ad una nuova versione dello schema
+}
+
+// sezioni registrate; se Data non è una mappa l'intero file è un'unica sezione senza nome
+func (set *Settings) sections() (map[string]interface{}, bool) {
+	sections, isMap := set.Data.(map[string]interface{})
+	if !isMap {
+		return map[string]interface{}{"": set.Data}, false
+	}
+
+	return sections, true
+}
+
+// deserializza il contenuto del file in nuove istanze delle sezioni.
+// fromCurrent: le nuove istanze partono dai valori attuali anzichè da zero.
+func (set *Settings) decode(fileContent []byte, fromCurrent bool) (*decoded, error) {
+	content, err := set.Codec.Decode(fileContent)
+	if err != nil {
+		return nil, err
+	}
+
+	sections, isMap := set.sections()
+
+	var raw map[string]json.RawMessage
 	if isMap {
-		// mappa che contiene strutture; deserializza ogni singola struttura, anche se in modo inefficiente
-		var temp map[string]interface{}
-		err = json.Unmarshal([]byte(fileContent), &temp)
+		err = json.Unmarshal(content, &raw)
 		if err != nil {
-			return err
+			return nil, err
 		}
+	} else {
+		raw = map[string]json.RawMessage{"": content}
+	}
 
-		for key, item := range temp {
-			tempj, _ := json.Marshal(item)
+	result := &decoded{
+		sections:   make(map[string]interface{}),
+		overridden: make(map[string]map[string]overriddenField),
+	}
+	var errs Errors
 
-			err := json.Unmarshal(tempj, data[key])
-			if err != nil {
-				return err
+	// le sezioni vengono processate sempre, anche se assenti nel file,
+	// in modo da applicare i default e la validazione
+	keys := make([]string, 0, len(sections))
+	for key := range sections {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		current := reflect.ValueOf(sections[key])
+		if current.Kind() != reflect.Ptr || current.IsNil() {
+			errs = append(errs, &FieldError{Section: key, Message: "data struct must be a pointer"})
+			continue
+		}
+
+		// la nuova istanza non deve condividere slice e map con quella attuale,
+		// che viene modificata soltanto se il file risulta valido
+		data := reflect.New(current.Type().Elem())
+		if fromCurrent {
+			set.dataLock.RLock()
+			data = copyData(current)
+			set.dataLock.RUnlock()
+		}
+
+		sectionRaw, migrated, err := set.migrate(key, raw[key])
+		if err != nil {
+			errs = append(errs, &FieldError{Section: key, Message: err.Error()})
+			continue
+		}
+		result.migrated = result.migrated || migrated
+
+		err = set.decodeSection(key, sectionRaw, data.Interface(), result)
+		if err != nil {
+			errs = append(errs, err.(Errors)...)
+			continue
+		}
+
+		result.sections[key] = data.Interface()
+	}
+
+	if set.Strict && isMap {
+		unknown := []string{}
+		for key := range raw {
+			if _, ok := sections[key]; !ok {
+				unknown = append(unknown, key)
 			}
 		}
-	} else {
-		err = json.Unmarshal([]byte(fileContent), &set.Data)
+		sort.Strings(unknown)
+
+		for _, key := range unknown {
+			errs = append(errs, &FieldError{Section: key, Message: "unknown section"})
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return result, nil
+}
+
+// deserializza una singola sezione applicando default, sovrascritture e validazione.
+// Gli errori ritornati sono sempre di tipo Errors.
+func (set *Settings) decodeSection(section string, raw json.RawMessage, data interface{}, result *decoded) error {
+	if d, ok := data.(Defaulter); ok {
+		d.SetDefaults()
+	}
+
+	if len(raw) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		if set.Strict {
+			dec.DisallowUnknownFields()
+		}
+
+		err := dec.Decode(data)
 		if err != nil {
-			return err
+			return Errors{decodeError(section, err)}
 		}
 	}
 
-	//fmt.Println(set)
+	err := set.applyOverrides(section, raw, data, result.overridden)
+	if err != nil {
+		return err
+	}
 
-	if set.Data == nil {
-		return errors.New("null settings in file")
+	if v, ok := data.(Validator); ok {
+		err = v.Validate()
+		if err != nil {
+			return sectionErrors(section, err)
+		}
 	}
 
 	return nil
 }
 
+// copia i valori deserializzati nelle strutture registrate
+func (set *Settings) apply(result *decoded) {
+	sections, _ := set.sections()
+
+	set.dataLock.Lock()
+	for key, data := range result.sections {
+		reflect.ValueOf(sections[key]).Elem().Set(reflect.ValueOf(data).Elem())
+	}
+	set.dataLock.Unlock()
+
+	set.overridden = result.overridden
+}
+
 // SaveSettings - salva le impostazioni
 func (set *Settings) SaveSettings() error {
 	if set.Data == nil {
@@ -105,42 +329,142 @@ func (set *Settings) SaveSettings() error {
 	set.lock.Lock()
 	defer set.lock.Unlock()
 
-	if set.verbose {
-		log.Println("Save settings")
+	return set.save()
+}
+
+// SaveStatus - esito dei salvataggi
+type SaveStatus struct {
+	LastSave  time.Time // ultimo salvataggio riuscito (zero se nessuno)
+	LastError error     // errore dell'ultimo salvataggio, nil se riuscito
+	Pending   bool      // salvataggio differito (SaveSettingsDebounce) in attesa
+}
+
+// SaveStatus - ritorna l'esito dell'ultimo salvataggio
+func (set *Settings) SaveStatus() SaveStatus {
+	set.timerLock.Lock()
+	pending := set.savePending
+	set.timerLock.Unlock()
+
+	set.statusLock.Lock()
+	defer set.statusLock.Unlock()
+
+	return SaveStatus{LastSave: set.lastSave, LastError: set.lastSaveErr, Pending: pending}
+}
+
+// salva le impostazioni; va invocata con set.lock acquisito
+func (set *Settings) save() (err error) {
+	set.log().Info("Save settings")
+
+	defer func() {
+		set.statusLock.Lock()
+		set.lastSaveErr = err
+		if err == nil {
+			set.lastSave = time.Now()
+		}
+		set.statusLock.Unlock()
+	}()
+
+	err = set.resolveFilename()
+	if err != nil {
+		return err
 	}
 
-	f, err := os.Create(set.filename)
+	// snapshot consistente: nessuna modifica tramite Update durante la serializzazione
+	set.dataLock.RLock()
+	data, err := json.Marshal(set.Data)
+	set.dataLock.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	data, err = set.restoreOverridden(data)
+	if err != nil {
+		return err
+	}
+
+	data, err = set.addSchemaVersions(data)
+	if err != nil {
+		return err
+	}
+
+	// il contenuto attuale permette al codec di preservare la formattazione del file
+	orig, _ := ioutil.ReadFile(set.filename)
+
+	b, err := set.Codec.Encode(data, orig)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	b, err := json.MarshalIndent(set.Data, "", "\t")
+	if !bytes.Equal(orig, b) && set.isValidContent(orig) {
+		err = set.rotateBackups(orig)
+		if err != nil {
+			set.log().Warn("Cannot write settings backup", "err", err)
+		}
+	}
+
+	// scrittura atomica: un crash durante il salvataggio non corrompe il file
+	err = fileutil.WriteAtomic(set.filename, b, fileMode)
 	if err != nil {
 		return err
 	}
-	err = ioutil.WriteFile(set.filename, b, 0666)
 
-	return err
+	// evita che il watcher interpreti il salvataggio come una modifica esterna
+	set.updateFileStat()
+
+	return nil
 }
 
 // SaveSettingsDebounce - salva le impostazioni dopo un certo ritardo dall'ultima invocazione.
 // Ogni invocazione resetta il il conteggio del timeout.
 func (set *Settings) SaveSettingsDebounce(saveAfter time.Duration) {
+	set.timerLock.Lock()
+	defer set.timerLock.Unlock()
+
+	set.savePending = true
+
 	if set.timerSave != nil {
 		set.timerSave.Reset(saveAfter)
 	} else {
 		saveFunc := func() {
-			set.SaveSettings()
+			set.timerLock.Lock()
+			set.savePending = false
+			set.timerLock.Unlock()
+
+			err := set.SaveSettings()
+			if err != nil {
+				set.log().Error("Cannot save settings", "err", err)
+			}
 		}
 
 		set.timerSave = time.AfterFunc(saveAfter, saveFunc)
 	}
 }
 
+// Update - esegue fn con accesso esclusivo ai dati; tutte le modifiche ai dati
+// registrati devono avvenire all'interno di fn, in modo che i salvataggi vedano
+// sempre uno stato consistente. fn non deve invocare Update, View o SaveSettings
+// (SaveSettingsDebounce è invece ammessa).
+func (set *Settings) Update(fn func()) {
+	set.dataLock.Lock()
+	defer set.dataLock.Unlock()
+
+	fn()
+}
+
+// View - esegue fn con accesso in lettura ai dati, concorrente con altre View
+// ma non con Update, Reload e la serializzazione per il salvataggio.
+func (set *Settings) View(fn func()) {
+	set.dataLock.RLock()
+	defer set.dataLock.RUnlock()
+
+	fn()
+}
+
 // New - restituisce un gestore inizializzato per dati custom
-// - filename: se vuoto viene automaticamente settato a settings.json
-// nel medesimo path dell'eseguibile.
+// - filename: se vuoto viene automaticamente settato a settings.json;
+// se relativo viene cercato nelle directory SearchPaths (directory di lavoro,
+// directory di configurazione dell'utente, directory dell'eseguibile). L'estensione determina il formato
+// (.json, .yaml/.yml, .toml oppure un codec registrato con RegisterCodec).
 // - data: passare il puntatore ad una struct contenente i dati
 // da caricare e salvare.
 func New(filename string, data interface{}, verbose bool) (*Settings, error) {
@@ -1,8 +1,14 @@
 package settings
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 type testSettingsData struct {
@@ -13,16 +19,16 @@ type testSettingsData struct {
 var testData testSettingsData
 var testSet *Settings
 
-func initTests(t *testing.T) error {
+// content: contenuto iniziale del file, in una directory temporanea
+func initTests(tb testing.TB, content string) error {
 	testData = testSettingsData{}
 
-	var err error
-	testSet, err = New("settings_test.json", &testData, false)
+	filename := writeTestFile(tb, "settings_test.json", content)
 
+	var err error
+	testSet, err = New(filename, &testData, false)
 	if err != nil {
-		if t != nil {
-			t.Error("Error init settings:", err)
-		}
+		tb.Error("Error init settings:", err)
 		return err
 	}
 
@@ -32,7 +38,7 @@ func initTests(t *testing.T) error {
 func TestSave(t *testing.T) {
 	fmt.Println("Save TEST")
 
-	err := initTests(t)
+	err := initTests(t, `{}`)
 	if err != nil {
 		return
 	}
@@ -46,7 +52,7 @@ func TestSave(t *testing.T) {
 func TestLoad(t *testing.T) {
 	fmt.Println("Load TEST")
 
-	err := initTests(t)
+	err := initTests(t, `{"Foo": 3, "Bar": "x"}`)
 	if err != nil {
 		return
 	}
@@ -55,14 +61,19 @@ func TestLoad(t *testing.T) {
 	if err != nil {
 		t.Error("Error loading settings:", err)
 	}
+	if testData.Foo != 3 || testData.Bar != "x" {
+		t.Error("Loaded data mismatch:", testData)
+	}
 }
 
 func TestLoadSave(t *testing.T) {
-	if t != nil {
-		fmt.Println("Save and reload TEST")
-	}
+	fmt.Println("Save and reload TEST")
+
+	testLoadSave(t)
+}
 
-	err := initTests(t)
+func testLoadSave(tb testing.TB) {
+	err := initTests(tb, `{}`)
 	if err != nil {
 		return
 	}
@@ -74,9 +85,7 @@ func TestLoadSave(t *testing.T) {
 
 	err = testSet.SaveSettings()
 	if err != nil {
-		if t != nil {
-			t.Error("Error saving settings:", err)
-		}
+		tb.Error("Error saving settings:", err)
 		return
 	}
 
@@ -85,22 +94,918 @@ func TestLoadSave(t *testing.T) {
 
 	err = testSet.LoadSettings()
 	if err != nil {
-		if t != nil {
-			t.Error("Error loading settings:", err)
-		}
+		tb.Error("Error loading settings:", err)
 		return
 	}
 
 	if testData != testData2 {
-		if t != nil {
-			t.Error("Loaded data mismatch: ", testData2, testData)
-		}
+		tb.Error("Loaded data mismatch: ", testData2, testData)
 		return
 	}
 }
 
 func BenchmarkLoadSave(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		TestLoadSave(nil)
+		testLoadSave(b)
+	}
+}
+
+type testSectionData struct {
+	Timeout int
+	Name    string
+}
+
+func (d *testSectionData) SetDefaults() {
+	if d.Timeout == 0 {
+		d.Timeout = 30
+	}
+}
+
+func (d *testSectionData) Validate() error {
+	if d.Timeout < 0 {
+		return NewFieldError("Timeout", "must be >= 0")
+	}
+	return nil
+}
+
+// crea un file di impostazioni temporaneo e ritorna il path assoluto
+func writeTestFile(t testing.TB, name string, content string) string {
+	dir, err := ioutil.TempDir("", "settings")
+	if err != nil {
+		t.Fatal("Error creating temp dir:", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	filename := filepath.Join(dir, name)
+
+	err = ioutil.WriteFile(filename, []byte(content), 0600)
+	if err != nil {
+		t.Fatal("Error writing test file:", err)
+	}
+
+	return filename
+}
+
+func TestDefaultsAndValidation(t *testing.T) {
+	filename := writeTestFile(t, "sections.json", `{"Section": {"Name": "x"}}`)
+
+	section := testSectionData{}
+	set, err := New(filename, map[string]interface{}{"Section": &section}, false)
+	if err != nil {
+		t.Fatal("Error init settings:", err)
+	}
+
+	err = set.LoadSettings()
+	if err != nil {
+		t.Fatal("Error loading settings:", err)
+	}
+	if section.Timeout != 30 || section.Name != "x" {
+		t.Error("Defaults not applied:", section)
+	}
+
+	ioutil.WriteFile(filename, []byte(`{"Section": {"Timeout": -1}}`), 0600)
+
+	err = set.LoadSettings()
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 1 {
+		t.Fatal("Expected validation errors, got:", err)
+	}
+	fe, ok := errs[0].(*FieldError)
+	if !ok || fe.Section != "Section" || fe.Field != "Timeout" {
+		t.Error("Unexpected validation error:", errs[0])
+	}
+}
+
+func TestStrict(t *testing.T) {
+	filename := writeTestFile(t, "strict.json", `{"Section": {"Timout": 5}, "Other": {}}`)
+
+	section := testSectionData{}
+	set, err := New(filename, map[string]interface{}{"Section": &section}, false)
+	if err != nil {
+		t.Fatal("Error init settings:", err)
+	}
+
+	err = set.LoadSettings()
+	if err != nil {
+		t.Error("Unknown keys must be ignored in non strict mode:", err)
+	}
+
+	set.Strict = true
+	err = set.LoadSettings()
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 2 {
+		t.Fatal("Expected unknown keys errors, got:", err)
+	}
+	if errs[0].Error() != "Section.Timout: unknown field" || errs[1].Error() != "Other: unknown section" {
+		t.Error("Unexpected errors:", errs)
+	}
+}
+
+func TestLoadJSONC(t *testing.T) {
+	filename, err := filepath.Abs("../settings.default.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var section struct {
+		SecureToken        string
+		CommandWord        string
+		SilenceTimeoutMins int
+	}
+	set, err := New(filename, map[string]interface{}{"Bot": &section}, false)
+	if err != nil {
+		t.Fatal("Error init settings:", err)
+	}
+
+	// il file è alla versione 1 dello schema del bot
+	set.RegisterMigration("Bot", 0, func(data json.RawMessage) (json.RawMessage, error) {
+		return data, nil
+	})
+
+	err = set.LoadSettings()
+	if err != nil {
+		t.Fatal("Error loading default settings:", err)
+	}
+	if section.CommandWord != "!" || section.SilenceTimeoutMins != 30 {
+		t.Error("Loaded data mismatch:", section)
+	}
+}
+
+func TestSaveJSONCPreservesComments(t *testing.T) {
+	content := "{\n" +
+		"\t// commento sezione\n" +
+		"\t\"Section\": {\n" +
+		"\t\t\"Timeout\": 10, // commento campo\n" +
+		"\t\t/* nome */ \"Name\": \"a\",\n" +
+		"\t},\n" +
+		"}\n"
+	filename := writeTestFile(t, "comments.json", content)
+
+	section := testSectionData{}
+	set, err := New(filename, map[string]interface{}{"Section": &section}, false)
+	if err != nil {
+		t.Fatal("Error init settings:", err)
+	}
+
+	err = set.LoadSettings()
+	if err != nil {
+		t.Fatal("Error loading settings:", err)
+	}
+
+	section.Name = "b"
+	err = set.SaveSettings()
+	if err != nil {
+		t.Fatal("Error saving settings:", err)
+	}
+
+	saved, _ := ioutil.ReadFile(filename)
+	expected := strings.Replace(content, `"a"`, `"b"`, 1)
+	if string(saved) != expected {
+		t.Errorf("Unexpected saved file:\n%s", saved)
+	}
+}
+
+func TestPatchJSONC(t *testing.T) {
+	orig := "{\n\t\"A\": 1, // uno\n\t\"B\": {\"X\": true},\n\t\"C\": [1, 2]\n}"
+	data := `{"A":1,"B":{"X":false,"Y":"new"},"D":{"Z":1}}`
+
+	patched, err := patchJSONC([]byte(orig), []byte(data))
+	if err != nil {
+		t.Fatal("Error patching:", err)
+	}
+
+	expected := "{\n\t\"A\": 1, // uno\n\t\"B\": {\"X\": false, \"Y\": \"new\"},\n\t\"D\": {\n\t\t\"Z\": 1\n\t}\n}"
+	if string(patched) != expected {
+		t.Errorf("Unexpected patch result:\n%s", patched)
+	}
+
+	var check map[string]interface{}
+	err = json.Unmarshal(StripJSONC(patched), &check)
+	if err != nil {
+		t.Error("Patched document is not valid:", err)
+	}
+}
+
+func TestPatchJSONCMembers(t *testing.T) {
+	cases := []struct {
+		orig, data, expected string
+	}{
+		// il commento sulla stessa riga viene rimosso insieme al membro
+		{
+			"{\n\t\"A\": 1, // uno\n\t\"B\": 2 // due\n}",
+			`{"A":1}`,
+			"{\n\t\"A\": 1, // uno\n}",
+		},
+		{
+			"{\n\t\"A\": 1, // uno\n\t\"B\": 2\n}",
+			`{"B":2}`,
+			"{\n\t\"B\": 2\n}",
+		},
+		{
+			"{\r\n\t\"A\": 1, // uno\r\n\t\"B\": 2 // due\r\n}",
+			`{"A":1}`,
+			"{\r\n\t\"A\": 1, // uno\r\n}",
+		},
+		// inserimento in un oggetto vuoto o svuotato, senza righe vuote
+		{
+			"{\n}",
+			`{"A":1}`,
+			"{\n\t\"A\": 1\n}",
+		},
+		{
+			"{}",
+			`{"A":1}`,
+			"{\n\t\"A\": 1\n}",
+		},
+		{
+			"{\n\t\"S\": {\n\t}\n}",
+			`{"S":{"A":1}}`,
+			"{\n\t\"S\": {\n\t\t\"A\": 1\n\t}\n}",
+		},
+		{
+			"{\n\t\"A\": 1 // uno\n}",
+			`{"B":2}`,
+			"{\n\t\"B\": 2\n}",
+		},
+		// chiavi scritte a mano con una grafia diversa da quella dei campi
+		{
+			"{\n\t\"securetoken\": \"x\", // token\n\t\"foo\": {\"bar\": 1}\n}",
+			`{"SecureToken":"y","Foo":{"Bar":2}}`,
+			"{\n\t\"securetoken\": \"y\", // token\n\t\"foo\": {\"bar\": 2}\n}",
+		},
+	}
+
+	for _, c := range cases {
+		patched, err := patchJSONC([]byte(c.orig), []byte(c.data))
+		if err != nil || string(patched) != c.expected {
+			t.Errorf("Unexpected patch result: %q -> %q, %v", c.orig, patched, err)
+		}
+	}
+}
+
+func TestCodecsLoadSave(t *testing.T) {
+	files := map[string]string{
+		"codec.yaml": "Section:\n  Timeout: 5\n  Name: yaml\n",
+		"codec.toml": "[Section]\nTimeout = 5\nName = \"toml\"\n",
+	}
+
+	for name, content := range files {
+		filename := writeTestFile(t, name, content)
+
+		section := testSectionData{}
+		set, err := New(filename, map[string]interface{}{"Section": &section}, false)
+		if err != nil {
+			t.Fatal("Error init settings:", err)
+		}
+
+		err = set.LoadSettings()
+		if err != nil {
+			t.Fatal("Error loading", name, err)
+		}
+		if section.Timeout != 5 || section.Name == "" {
+			t.Error("Loaded data mismatch:", name, section)
+		}
+
+		section.Timeout = 7
+		err = set.SaveSettings()
+		if err != nil {
+			t.Fatal("Error saving", name, err)
+		}
+
+		saved := section
+		section = testSectionData{}
+		err = set.LoadSettings()
+		if err != nil {
+			t.Fatal("Error reloading", name, err)
+		}
+		if section != saved {
+			t.Error("Reloaded data mismatch:", name, saved, section)
+		}
+	}
+}
+
+func TestYAMLEncodeComments(t *testing.T) {
+	orig := `# configurazione
+Section:
+  # secondi
+  timeout: 5 # timeout
+  Name: "yaml"
+  Old: 1 # rimosso
+Other: [1, 2] # lista
+`
+	data := `{"Section":{"Timeout":7,"Name":"yaml","New":true},"Other":[1,2]}`
+
+	out, err := YAMLCodec{}.Encode([]byte(data), []byte(orig))
+	if err != nil {
+		t.Fatal("Encode error:", err)
+	}
+
+	expected := `# configurazione
+Section:
+  # secondi
+  timeout: 7 # timeout
+  Name: "yaml"
+  New: true
+Other: [1, 2] # lista
+`
+	if string(out) != expected {
+		t.Errorf("Encode() = %q, want %q", out, expected)
+	}
+
+	decoded, err := YAMLCodec{}.Decode(out)
+	if err != nil {
+		t.Fatal("Decode error:", err)
+	}
+	if compact, _ := compactJSON(decoded); compact != `{"Other":[1,2],"Section":{"Name":"yaml","New":true,"timeout":7}}` {
+		t.Error("Unexpected decoded data:", compact)
+	}
+}
+
+// TOML non preserva i commenti: il file viene riscritto dai dati
+func TestTOMLEncodeComments(t *testing.T) {
+	orig := "# configurazione\n[Section]\nTimeout = 5 # secondi\n"
+
+	out, err := TOMLCodec{}.Encode([]byte(`{"Section":{"Timeout":7}}`), []byte(orig))
+	if err != nil {
+		t.Fatal("Encode error:", err)
+	}
+
+	if strings.Contains(string(out), "#") {
+		t.Error("Comments preserved:", string(out))
+	}
+	if !strings.Contains(string(out), "Timeout = 7") {
+		t.Error("Unexpected content:", string(out))
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	filename := writeTestFile(t, "overrides.json", `{"Section": {"Timeout": 5, "Name": "file"}}`)
+
+	section := testSectionData{}
+	set, err := New(filename, map[string]interface{}{"Section": &section}, false)
+	if err != nil {
+		t.Fatal("Error init settings:", err)
+	}
+
+	set.EnvPrefix = "SETTINGSTEST"
+	os.Setenv("SETTINGSTEST_SECTION_NAME", "env")
+	defer os.Unsetenv("SETTINGSTEST_SECTION_NAME")
+
+	err = set.OverrideFlag().Set("section.timeout=9")
+	if err != nil {
+		t.Fatal("Error setting flag:", err)
+	}
+
+	err = set.LoadSettings()
+	if err != nil {
+		t.Fatal("Error loading settings:", err)
+	}
+	if section.Timeout != 9 || section.Name != "env" {
+		t.Error("Overrides not applied:", section)
+	}
+	if !set.IsOverridden("Section", "Name") {
+		t.Error("Name must be overridden")
+	}
+
+	err = set.SaveSettings()
+	if err != nil {
+		t.Fatal("Error saving settings:", err)
+	}
+
+	saved, _ := ioutil.ReadFile(filename)
+	if string(saved) != `{"Section": {"Timeout": 5, "Name": "file"}}` {
+		t.Error("Overridden values saved to file:", string(saved))
+	}
+
+	err = set.OverrideFlag().Set("section.timeout=abc")
+	if err != nil {
+		t.Fatal("Error setting flag:", err)
+	}
+	err = set.LoadSettings()
+	if err == nil {
+		t.Error("Invalid override must fail")
+	}
+}
+
+func TestEnvOverridesSections(t *testing.T) {
+	filename := writeTestFile(t, "envsections.json", `{"My": {"Name": "file"}, "My_scope": {"Name": "file"}}`)
+
+	my := testSectionData{}
+	myScope := testSectionData{}
+	set, err := New(filename, map[string]interface{}{"My": &my, "My_scope": &myScope}, false)
+	if err != nil {
+		t.Fatal("Error init settings:", err)
+	}
+	set.EnvPrefix = "SETTINGSTEST"
+	set.Strict = true
+
+	env := map[string]string{
+		"SETTINGSTEST_MY_NAME":       "my",
+		"SETTINGSTEST_MY_SCOPE_NAME": "scope",
+		// sezione inesistente: ignorata anche in modalità strict
+		"SETTINGSTEST_OTHER_NAME": "other",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	// la variabile della sezione dal nome più lungo non appartiene a quella più corta
+	err = set.LoadSettings()
+	if err != nil {
+		t.Fatal("Error loading settings:", err)
+	}
+	if my.Name != "my" || myScope.Name != "scope" {
+		t.Error("Unexpected overrides:", my, myScope)
+	}
+
+	// campo sconosciuto di una sezione esistente
+	os.Setenv("SETTINGSTEST_MY_SCOPE_UNKNOWN", "x")
+	defer os.Unsetenv("SETTINGSTEST_MY_SCOPE_UNKNOWN")
+
+	err = set.LoadSettings()
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 1 || errs[0].(*FieldError).Section != "My_scope" {
+		t.Error("Unknown field must fail in strict mode:", err)
+	}
+
+	set.Strict = false
+	err = set.LoadSettings()
+	if err != nil {
+		t.Error("Unknown field must be ignored:", err)
+	}
+}
+
+func TestSetJSONMember(t *testing.T) {
+	cases := []struct {
+		key, value, expected string
+	}{
+		{"A", "3", `{"A":3,"B":2}`},
+		{"B", "", `{"A":1}`},
+		{"A", "", `{"B":2}`},
+		{"C", `"x"`, `{"A":1,"B":2,"C":"x"}`},
+	}
+
+	for _, c := range cases {
+		var value []byte
+		if c.value != "" {
+			value = []byte(c.value)
+		}
+
+		out, err := setJSONMember([]byte(`{"A":1,"B":2}`), c.key, value)
+		if err != nil || string(out) != c.expected {
+			t.Error("Unexpected result:", c.key, string(out), err)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	filename := writeTestFile(t, "reload.json", `{"Section": {"Timeout": 5, "Name": "a"}, "Other": {"Foo": 1}}`)
+
+	section := testSectionData{}
+	other := testSettingsData{}
+	set, err := New(filename, map[string]interface{}{"Section": &section, "Other": &other}, false)
+	if err != nil {
+		t.Fatal("Error init settings:", err)
+	}
+
+	var notified []string
+	set.OnChange = func(name string, old interface{}, new interface{}) {
+		notified = append(notified, name)
+		if name == "Section" && old.(*testSectionData).Name != "a" {
+			t.Error("Unexpected old value:", old)
+		}
+	}
+
+	err = set.LoadSettings()
+	if err != nil {
+		t.Fatal("Error loading settings:", err)
+	}
+
+	// non valido: le impostazioni attuali non devono cambiare
+	ioutil.WriteFile(filename, []byte(`{"Section": {"Timeout": -1, "Name": "b"}, "Other": {"Foo": 1}}`), 0600)
+	_, err = set.Reload()
+	if err == nil || section.Name != "a" {
+		t.Error("Invalid settings must not be applied:", err, section)
+	}
+
+	ioutil.WriteFile(filename, []byte(`{"Section": {"Name": "b"}, "Other": {"Foo": 1}}`), 0600)
+	changed, err := set.Reload()
+	if err != nil {
+		t.Fatal("Error reloading settings:", err)
+	}
+	if len(changed) != 1 || changed[0] != "Section" || len(notified) != 1 {
+		t.Error("Unexpected changed sections:", changed, notified)
+	}
+	if section.Name != "b" || section.Timeout != 30 {
+		t.Error("Reloaded data mismatch:", section)
+	}
+}
+
+func TestBackupRecovery(t *testing.T) {
+	filename := writeTestFile(t, "backup.json", `{"Section": {"Timeout": 5, "Name": "a"}}`)
+
+	section := testSectionData{}
+	set, err := New(filename, map[string]interface{}{"Section": &section}, false)
+	if err != nil {
+		t.Fatal("Error init settings:", err)
+	}
+
+	err = set.LoadSettings()
+	if err != nil {
+		t.Fatal("Error loading settings:", err)
+	}
+
+	section.Name = "b"
+	err = set.SaveSettings()
+	if err != nil {
+		t.Fatal("Error saving settings:", err)
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Error("Unexpected settings file permissions:", info.Mode(), err)
+	}
+
+	backup, err := ioutil.ReadFile(filename + ".bak.1")
+	if err != nil || string(backup) != `{"Section": {"Timeout": 5, "Name": "a"}}` {
+		t.Error("Unexpected backup:", string(backup), err)
+	}
+
+	// file troncato da un crash
+	ioutil.WriteFile(filename, []byte(`{"Section": {"Time`), 0600)
+
+	section = testSectionData{}
+	err = set.LoadSettings()
+	if err != nil {
+		t.Fatal("Settings must be recovered from backup:", err)
+	}
+	if section.Name != "a" {
+		t.Error("Recovered data mismatch:", section)
+	}
+}
+
+type testListData struct {
+	N      int
+	Names  []string
+	ByChat map[string][]string
+}
+
+func (d *testListData) Validate() error {
+	if d.N < 0 {
+		return NewFieldError("N", "neg")
+	}
+	return nil
+}
+
+func TestInvalidLoadKeepsCurrent(t *testing.T) {
+	filename := writeTestFile(t, "invalid.json", `{"S": {"N": 1, "Names": ["a", "b"], "ByChat": {"1": ["x"]}}}`)
+
+	data := testListData{}
+	set, err := New(filename, map[string]interface{}{"S": &data}, false)
+	if err != nil {
+		t.Fatal("Error init settings:", err)
+	}
+
+	err = set.LoadSettings()
+	if err != nil {
+		t.Fatal("Error loading settings:", err)
+	}
+
+	// file non valido: i valori attuali non devono cambiare, nemmeno slice e map
+	ioutil.WriteFile(filename, []byte(`{"S": {"N": -1, "Names": ["z"], "ByChat": {"1": ["y"], "2": ["w"]}}}`), 0600)
+
+	err = set.LoadSettings()
+	if err == nil {
+		t.Fatal("Invalid settings must be rejected")
+	}
+
+	if data.N != 1 || len(data.Names) != 2 || data.Names[0] != "a" || data.Names[1] != "b" {
+		t.Error("Live values changed by a rejected file:", data)
+	}
+	if len(data.ByChat) != 1 || len(data.ByChat["1"]) != 1 || data.ByChat["1"][0] != "x" {
+		t.Error("Live map changed by a rejected file:", data.ByChat)
+	}
+
+	_, err = set.Reload()
+	if err == nil {
+		t.Fatal("Invalid settings must be rejected by Reload")
+	}
+	if data.Names[0] != "a" || data.ByChat["1"][0] != "x" {
+		t.Error("Live values changed by a rejected reload:", data)
+	}
+}
+
+func TestConcurrentUpdateSave(t *testing.T) {
+	filename := writeTestFile(t, "concurrent.json", `{"Section": {"Timeout": 1}}`)
+
+	section := testSectionData{}
+	set, err := New(filename, map[string]interface{}{"Section": &section}, false)
+	if err != nil {
+		t.Fatal("Error init settings:", err)
+	}
+
+	done := make(chan bool)
+	for i := 0; i < 4; i++ {
+		go func(i int) {
+			for j := 0; j < 50; j++ {
+				set.Update(func() {
+					section.Timeout = i*100 + j
+					section.Name = fmt.Sprint(i, j)
+				})
+				set.SaveSettingsDebounce(time.Millisecond)
+			}
+			done <- true
+		}(i)
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+
+	set.timerLock.Lock()
+	set.timerSave.Stop()
+	set.timerLock.Unlock()
+
+	err = set.SaveSettings()
+	if err != nil {
+		t.Fatal("Error saving settings:", err)
+	}
+
+	var saved testSectionData
+	set.View(func() {
+		saved = section
+	})
+
+	section = testSectionData{}
+	err = set.LoadSettings()
+	if err != nil || section != saved {
+		t.Error("Inconsistent saved data:", saved, section, err)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	filename := writeTestFile(t, "migrate.json", `{"Section": {"Timeout": 5, "Title": "old"}}`)
+
+	section := testSectionData{}
+	set, err := New(filename, map[string]interface{}{"Section": &section}, false)
+	if err != nil {
+		t.Fatal("Error init settings:", err)
+	}
+	set.Strict = true
+
+	// v0 -> v1: Title rinominato in Name
+	set.RegisterMigration("Section", 0, func(data json.RawMessage) (json.RawMessage, error) {
+		var fields map[string]interface{}
+		err := json.Unmarshal(data, &fields)
+		if err != nil {
+			return nil, err
+		}
+		fields["Name"] = fields["Title"]
+		delete(fields, "Title")
+		return json.Marshal(fields)
+	})
+	// v1 -> v2: Timeout in minuti anzichè secondi
+	set.RegisterMigration("Section", 1, func(data json.RawMessage) (json.RawMessage, error) {
+		var fields map[string]interface{}
+		err := json.Unmarshal(data, &fields)
+		if err != nil {
+			return nil, err
+		}
+		fields["Timeout"] = fields["Timeout"].(float64) * 60
+		return json.Marshal(fields)
+	})
+
+	if set.SchemaVersion("Section") != 2 {
+		t.Error("Unexpected schema version:", set.SchemaVersion("Section"))
+	}
+
+	err = set.LoadSettings()
+	if err != nil {
+		t.Fatal("Error loading settings:", err)
+	}
+	if section.Name != "old" || section.Timeout != 300 {
+		t.Error("Migrated data mismatch:", section)
+	}
+
+	backups, _ := filepath.Glob(filename + ".pre-migration-*.bak")
+	if len(backups) != 1 {
+		t.Error("Missing pre-migration backup:", backups)
+	}
+
+	// il file salvato è alla versione attuale e non viene migrato nuovamente
+	section = testSectionData{}
+	err = set.LoadSettings()
+	if err != nil {
+		t.Fatal("Error reloading migrated settings:", err)
+	}
+	if section.Name != "old" || section.Timeout != 300 {
+		t.Error("Reloaded data mismatch:", section)
+	}
+
+	saved, _ := ioutil.ReadFile(filename)
+	if !strings.Contains(string(saved), `"_version": 2`) {
+		t.Error("Schema version not saved:", string(saved))
+	}
+}
+
+func TestReloadMigrations(t *testing.T) {
+	filename := writeTestFile(t, "reloadmigrate.json", `{"Section": {"_version": 1, "Timeout": 5, "Name": "a"}}`)
+
+	section := testSectionData{}
+	set, err := New(filename, map[string]interface{}{"Section": &section}, false)
+	if err != nil {
+		t.Fatal("Error init settings:", err)
+	}
+	set.RegisterMigration("Section", 0, func(data json.RawMessage) (json.RawMessage, error) {
+		return json.RawMessage(strings.Replace(string(data), `"Title"`, `"Name"`, 1)), nil
+	})
+
+	err = set.LoadSettings()
+	if err != nil {
+		t.Fatal("Error loading settings:", err)
+	}
+
+	// file sostituito esternamente con uno alla versione precedente
+	ioutil.WriteFile(filename, []byte(`{"Section": {"Timeout": 5, "Title": "b"}}`), 0600)
+	_, err = set.Reload()
+	if err != nil {
+		t.Fatal("Error reloading settings:", err)
+	}
+	if section.Name != "b" {
+		t.Error("Migrated data mismatch:", section)
+	}
+
+	saved, _ := ioutil.ReadFile(filename)
+	if !strings.Contains(string(saved), `"_version": 1`) || strings.Contains(string(saved), "Title") {
+		t.Error("Migrated settings not saved:", string(saved))
+	}
+	backups, _ := filepath.Glob(filename + ".pre-migration-*.bak")
+	if len(backups) != 1 {
+		t.Error("Missing pre-migration backup:", backups)
+	}
+	if set.fileChanged() {
+		t.Error("Saved file reported as changed")
+	}
+}
+
+func TestSearchPaths(t *testing.T) {
+	found := writeTestFile(t, "search.json", `{"Foo": 7}`)
+	empty := filepath.Dir(writeTestFile(t, "other.json", `{}`))
+
+	data := testSettingsData{}
+	set, err := New("search.json", &data, false)
+	if err != nil {
+		t.Fatal("Error init settings:", err)
+	}
+	set.SearchPaths = []string{empty, filepath.Dir(found)}
+
+	err = set.LoadSettings()
+	if err != nil {
+		t.Fatal("Error loading settings:", err)
+	}
+	if set.Filename() != found || data.Foo != 7 {
+		t.Error("File not found in search paths:", set.Filename(), data)
+	}
+
+	// file assente: viene creato dal contenuto di default nella prima directory
+	data = testSettingsData{}
+	set, _ = New("", &data, false)
+	set.SearchPaths = []string{filepath.Join(empty, "sub"), filepath.Dir(found)}
+	set.DefaultContent = []byte(`{"Bar": "default"}`)
+
+	err = set.LoadSettings()
+	if err != nil {
+		t.Fatal("Error loading default settings:", err)
+	}
+
+	created := filepath.Join(empty, "sub", defaultFilename)
+	if set.Filename() != created || data.Bar != "default" {
+		t.Error("Default file not created:", set.Filename(), data)
+	}
+	if _, err := os.Stat(created); err != nil {
+		t.Error("Default file missing:", err)
+	}
+
+	// file assente senza contenuto di default
+	set, _ = New("missing.json", &data, false)
+	set.SearchPaths = []string{empty}
+
+	err = set.LoadSettings()
+	if !os.IsNotExist(err) {
+		t.Error("Missing file must be reported:", err)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	type credentials struct {
+		User     string
+		Password string `secret:"true"`
+	}
+	type section struct {
+		Token    string `json:"token" secret:"true"`
+		Empty    string `secret:"true"`
+		Name     string
+		Accounts []credentials
+	}
+
+	data := &section{
+		Token:    "123:abc",
+		Name:     "bot",
+		Accounts: []credentials{{"user", "pass"}},
+	}
+
+	b, err := Redact(map[string]interface{}{"Section": data})
+	if err != nil {
+		t.Fatal("Error redacting:", err)
+	}
+
+	s := string(b)
+	if strings.Contains(s, "123:abc") || strings.Contains(s, "pass\"") {
+		t.Error("Secret not redacted:", s)
+	}
+	if !strings.Contains(s, `"token":"`+RedactedMask+`"`) || !strings.Contains(s, `"Empty":""`) ||
+		!strings.Contains(s, `"Name":"bot"`) || !strings.Contains(s, `"User":"user"`) {
+		t.Error("Unexpected redacted data:", s)
+	}
+
+	if data.Token != "123:abc" {
+		t.Error("Original data modified")
+	}
+}
+
+func TestSetValue(t *testing.T) {
+	filename := writeTestFile(t, "edit.json", `{"Section": {"Timeout": 10}}`)
+
+	section := testSectionData{}
+	set, err := New(filename, map[string]interface{}{"Section": &section}, false)
+	if err != nil {
+		t.Fatal("Error init settings:", err)
+	}
+
+	err = set.LoadSettings()
+	if err != nil {
+		t.Fatal("Error loading settings:", err)
+	}
+
+	var changed []string
+	set.OnChange = func(name string, old interface{}, new interface{}) {
+		changed = append(changed, name)
+		if old.(*testSectionData).Timeout != 10 || new.(*testSectionData).Timeout != 20 {
+			t.Error("Unexpected change:", old, new)
+		}
+	}
+
+	key, err := set.SetValue("section", "timeout", "20")
+	if err != nil || key != "Timeout" || section.Timeout != 20 {
+		t.Error("Value not set:", key, err, section)
+	}
+	if len(changed) != 1 || changed[0] != "Section" {
+		t.Error("Change not notified:", changed)
+	}
+
+	_, err = set.SetValue("Section", "Timeout", "abc")
+	if err == nil {
+		t.Error("Invalid type accepted")
+	}
+	_, err = set.SetValue("Section", "Timeout", "-1")
+	if _, ok := err.(Errors); !ok || section.Timeout != 20 {
+		t.Error("Invalid value applied:", err, section)
+	}
+	_, err = set.SetValue("Section", "Unknown", "1")
+	if err == nil {
+		t.Error("Unknown field accepted")
+	}
+
+	_, value, err := set.GetValue("Section", "TIMEOUT")
+	if err != nil || string(value) != "20" {
+		t.Error("Unexpected value:", string(value), err)
+	}
+}
+
+func TestSaveStatus(t *testing.T) {
+	filename := writeTestFile(t, "status.json", `{"Foo": 1}`)
+
+	data := testSettingsData{}
+	set, err := New(filename, &data, false)
+	if err != nil {
+		t.Fatal("Error init settings:", err)
+	}
+
+	status := set.SaveStatus()
+	if !status.LastSave.IsZero() || status.LastError != nil || status.Pending {
+		t.Error("Unexpected initial status:", status)
+	}
+
+	set.SaveSettingsDebounce(time.Hour)
+	defer set.timerSave.Stop()
+
+	if !set.SaveStatus().Pending {
+		t.Error("Debounced save not pending")
+	}
+
+	err = set.SaveSettings()
+	status = set.SaveStatus()
+	if err != nil || status.LastSave.IsZero() || status.LastError != nil {
+		t.Error("Unexpected status after save:", status, err)
 	}
 }
@@ -0,0 +1,113 @@
+package settings
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Defaulter - interfaccia opzionale di una sezione.
+// SetDefaults viene invocata prima della deserializzazione: i valori presenti
+// nel file sovrascrivono quelli di default.
+type Defaulter interface {
+	SetDefaults()
+}
+
+// Validator - interfaccia opzionale di una sezione.
+// Validate viene invocata dopo la deserializzazione; può ritornare un FieldError,
+// un elenco Errors oppure un errore generico.
+type Validator interface {
+	Validate() error
+}
+
+// FieldError - errore relativo ad una sezione ed eventualmente ad un suo campo.
+type FieldError struct {
+	Section string
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	var where string
+
+	switch {
+	case e.Section != "" && e.Field != "":
+		where = e.Section + "." + e.Field
+	case e.Section != "":
+		where = e.Section
+	default:
+		where = e.Field
+	}
+
+	if where == "" {
+		return e.Message
+	}
+
+	return where + ": " + e.Message
+}
+
+// NewFieldError - errore di validazione di un campo; la sezione viene
+// valorizzata automaticamente durante il caricamento.
+func NewFieldError(field string, message string) *FieldError {
+	return &FieldError{Field: field, Message: message}
+}
+
+// Errors - elenco di errori riportati insieme
+type Errors []error
+
+func (errs Errors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// converte l'errore di validazione di una sezione in un elenco di FieldError
+func sectionErrors(section string, err error) Errors {
+	var errs Errors
+
+	switch e := err.(type) {
+	case Errors:
+		for _, item := range e {
+			errs = append(errs, sectionErrors(section, item)...)
+		}
+
+	case *FieldError:
+		fe := *e
+		if fe.Section == "" {
+			fe.Section = section
+		}
+		errs = append(errs, &fe)
+
+	default:
+		errs = append(errs, &FieldError{Section: section, Message: err.Error()})
+	}
+
+	return errs
+}
+
+// converte un errore di deserializzazione in FieldError
+func decodeError(section string, err error) *FieldError {
+	switch e := err.(type) {
+	case *json.UnmarshalTypeError:
+		return &FieldError{
+			Section: section,
+			Field:   e.Field,
+			Message: "cannot use " + e.Value + " as " + e.Type.String(),
+		}
+	}
+
+	// json non espone un tipo per i campi sconosciuti
+	const unknownPrefix = "json: unknown field "
+	msg := err.Error()
+	if strings.HasPrefix(msg, unknownPrefix) {
+		return &FieldError{
+			Section: section,
+			Field:   strings.Trim(msg[len(unknownPrefix):], "\""),
+			Message: "unknown field",
+		}
+	}
+
+	return &FieldError{Section: section, Message: msg}
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltStore - store su database embedded bbolt: un bucket per namespace,
+// chiavi ordinate e ricerca per prefisso efficiente anche con molti record.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBolt - apre (o crea) il database; fallisce se già aperto da un altro processo
+func OpenBolt(filename string) (*BoltStore, error) {
+	db, err := bolt.Open(filename, fileMode, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+func (store *BoltStore) Get(namespace string, key string) (value []byte, ok bool, err error) {
+	err = store.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(namespace))
+		if bucket == nil {
+			return nil
+		}
+
+		v := bucket.Get([]byte(key))
+		if v != nil {
+			// v è valido solo all'interno della transazione
+			value = append([]byte{}, v...)
+			ok = true
+		}
+		return nil
+	})
+
+	return
+}
+
+func (store *BoltStore) Set(namespace string, key string, value []byte) error {
+	if !json.Valid(value) {
+		return ErrInvalidValue
+	}
+
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(namespace))
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(key), value)
+	})
+}
+
+func (store *BoltStore) Delete(namespace string, key string) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(namespace))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.Delete([]byte(key))
+	})
+}
+
+func (store *BoltStore) List(namespace string, prefix string) ([]string, error) {
+	keys := []string{}
+
+	err := store.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(namespace))
+		if bucket == nil {
+			return nil
+		}
+
+		p := []byte(prefix)
+		c := bucket.Cursor()
+		for k, _ := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, _ = c.Next() {
+			keys = append(keys, string(k))
+		}
+		return nil
+	})
+
+	return keys, err
+}
+
+func (store *BoltStore) Close() error {
+	return store.db.Close()
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrReservedKey - chiave riservata ai dati di utenti e chat (vedi Handle.User e Handle.Chat)
+var ErrReservedKey = errors.New("storage: key prefix reserved to users and chats")
+
+// prefissi delle chiavi di User e Chat
+const (
+	userPrefix = "user/"
+	chatPrefix = "chat/"
+)
+
+// Handle - accesso ad un namespace con valori serializzati in JSON,
+// eventualmente ristretto ai dati di un utente o di una chat.
+// Sull'intero namespace le chiavi che iniziano con "user/" e "chat/" sono
+// riservate: possono essere lette ed elencate, ma non modificate (ErrReservedKey).
+type Handle struct {
+	ns     *Namespace
+	prefix string
+}
+
+// NewHandle - ritorna l'accesso all'intero namespace
+func NewHandle(ns *Namespace) *Handle {
+	return &Handle{ns: ns}
+}
+
+// User - ritorna l'accesso ai soli dati dell'utente
+func (h *Handle) User(userID int) *Handle {
+	return h.sub(userPrefix + strconv.Itoa(userID) + "/")
+}
+
+// Chat - ritorna l'accesso ai soli dati della chat
+func (h *Handle) Chat(chatID int64) *Handle {
+	return h.sub(chatPrefix + strconv.FormatInt(chatID, 10) + "/")
+}
+
+func (h *Handle) sub(prefix string) *Handle {
+	return &Handle{ns: h.ns, prefix: h.prefix + prefix}
+}
+
+// le chiavi riservate non sono modificabili dall'intero namespace, altrimenti
+// si sovrapporrebbero a quelle di utenti e chat
+func (h *Handle) checkKey(key string) error {
+	if h.prefix == "" && (strings.HasPrefix(key, userPrefix) || strings.HasPrefix(key, chatPrefix)) {
+		return ErrReservedKey
+	}
+	return nil
+}
+
+// Get - deserializza in value il valore della chiave; ritorna false se non esiste
+func (h *Handle) Get(key string, value interface{}) (bool, error) {
+	raw, ok, err := h.ns.Get(h.prefix + key)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	return true, json.Unmarshal(raw, value)
+}
+
+// Set - salva il valore serializzato in JSON
+func (h *Handle) Set(key string, value interface{}) error {
+	if err := h.checkKey(key); err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return h.ns.Set(h.prefix+key, raw)
+}
+
+func (h *Handle) Delete(key string) error {
+	if err := h.checkKey(key); err != nil {
+		return err
+	}
+
+	return h.ns.Delete(h.prefix + key)
+}
+
+// List - ritorna, in ordine, le chiavi che iniziano con prefix
+// (relative all'utente o alla chat se l'accesso è ristretto)
+func (h *Handle) List(prefix string) ([]string, error) {
+	keys, err := h.ns.List(h.prefix + prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, h.prefix)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/marcozaccari/AssistantBot/internal/fileutil"
+)
+
+// permessi del file: lo stato contiene dati degli utenti
+const fileMode os.FileMode = 0600
+
+// JSONFileStore - store su singolo file JSON, mantenuto interamente in memoria
+// e riscritto in modo atomico ad ogni modifica. Adatto a piccole quantità di dati.
+type JSONFileStore struct {
+	filename string
+
+	lock sync.RWMutex
+	data map[string]map[string]json.RawMessage // namespace -> chiave -> valore
+}
+
+// OpenJSONFile - apre lo store su file JSON; il file viene creato alla prima modifica
+func OpenJSONFile(filename string) (*JSONFileStore, error) {
+	store := JSONFileStore{
+		filename: filename,
+		data:     make(map[string]map[string]json.RawMessage),
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &store, nil
+		}
+		return nil, err
+	}
+
+	if len(strings.TrimSpace(string(content))) > 0 {
+		err = json.Unmarshal(content, &store.data)
+		if err != nil {
+			return nil, err
+		}
+
+		// il file è indentato: i valori vengono restituiti in forma compatta
+		for _, values := range store.data {
+			for key, value := range values {
+				values[key] = compact(value)
+			}
+		}
+	}
+
+	return &store, nil
+}
+
+func (store *JSONFileStore) Get(namespace string, key string) ([]byte, bool, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	value, ok := store.data[namespace][key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	return append([]byte{}, value...), true, nil
+}
+
+func (store *JSONFileStore) Set(namespace string, key string, value []byte) error {
+	if !json.Valid(value) {
+		return ErrInvalidValue
+	}
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	rollback := store.snapshot(namespace, key)
+
+	if store.data[namespace] == nil {
+		store.data[namespace] = make(map[string]json.RawMessage)
+	}
+	store.data[namespace][key] = compact(value)
+
+	err := store.save()
+	if err != nil {
+		rollback()
+	}
+
+	return err
+}
+
+func (store *JSONFileStore) Delete(namespace string, key string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	if _, ok := store.data[namespace][key]; !ok {
+		return nil
+	}
+
+	rollback := store.snapshot(namespace, key)
+
+	delete(store.data[namespace], key)
+	if len(store.data[namespace]) == 0 {
+		delete(store.data, namespace)
+	}
+
+	err := store.save()
+	if err != nil {
+		rollback()
+	}
+
+	return err
+}
+
+// ritorna la funzione che ripristina il valore attuale della chiave, in modo che
+// la memoria resti allineata al file se il salvataggio fallisce.
+// Va invocata con il lock acquisito.
+func (store *JSONFileStore) snapshot(namespace string, key string) func() {
+	value, ok := store.data[namespace][key]
+
+	return func() {
+		if !ok {
+			delete(store.data[namespace], key)
+			if len(store.data[namespace]) == 0 {
+				delete(store.data, namespace)
+			}
+			return
+		}
+
+		if store.data[namespace] == nil {
+			store.data[namespace] = make(map[string]json.RawMessage)
+		}
+		store.data[namespace][key] = value
+	}
+}
+
+func (store *JSONFileStore) List(namespace string, prefix string) ([]string, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	keys := []string{}
+	for key := range store.data[namespace] {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+func (store *JSONFileStore) Close() error {
+	return nil
+}
+
+// va invocata con il lock acquisito
+func (store *JSONFileStore) save() error {
+	b, err := json.MarshalIndent(store.data, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return fileutil.WriteAtomic(store.filename, b, fileMode)
+}
+
+func compact(value []byte) json.RawMessage {
+	var buf bytes.Buffer
+	json.Compact(&buf, value)
+
+	return buf.Bytes()
+}
@@ -0,0 +1,69 @@
+// Package storage - archiviazione dello stato di runtime in un key-value store,
+// separato dalla configurazione statica gestita dal package settings.
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// Store - key-value store suddiviso in namespace; i valori sono documenti JSON.
+// Le implementazioni devono essere utilizzabili da più goroutine.
+type Store interface {
+	// Get ritorna il valore della chiave; ok è false se la chiave non esiste
+	Get(namespace string, key string) (value []byte, ok bool, err error)
+	Set(namespace string, key string, value []byte) error
+	Delete(namespace string, key string) error
+
+	// List ritorna, in ordine, le chiavi del namespace che iniziano con prefix
+	List(namespace string, prefix string) ([]string, error)
+
+	Close() error
+}
+
+// ErrInvalidValue - il valore non è un documento JSON valido
+var ErrInvalidValue = errors.New("storage: value is not valid JSON")
+
+// Open - apre (o crea) lo store scegliendo l'implementazione in base all'estensione:
+// .db o .bolt per il database embedded (bbolt), altrimenti un file JSON.
+func Open(filename string) (Store, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".db", ".bolt":
+		return OpenBolt(filename)
+	default:
+		return OpenJSONFile(filename)
+	}
+}
+
+// Namespace - accesso ad un singolo namespace dello store
+type Namespace struct {
+	store Store
+	name  string
+}
+
+// NewNamespace - ritorna l'accesso al namespace name dello store
+func NewNamespace(store Store, name string) *Namespace {
+	return &Namespace{store: store, name: name}
+}
+
+// Name - nome del namespace
+func (ns *Namespace) Name() string {
+	return ns.name
+}
+
+func (ns *Namespace) Get(key string) ([]byte, bool, error) {
+	return ns.store.Get(ns.name, key)
+}
+
+func (ns *Namespace) Set(key string, value []byte) error {
+	return ns.store.Set(ns.name, key, value)
+}
+
+func (ns *Namespace) Delete(key string) error {
+	return ns.store.Delete(ns.name, key)
+}
+
+func (ns *Namespace) List(prefix string) ([]string, error) {
+	return ns.store.List(ns.name, prefix)
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testStore(t *testing.T, filename string) {
+	store, err := Open(filename)
+	if err != nil {
+		t.Fatal("Error opening store:", err)
+	}
+
+	ns := NewNamespace(store, "Scope")
+
+	for _, key := range []string{"users/2", "users/1", "chats/1"} {
+		err = ns.Set(key, []byte(`{"key":"`+key+`"}`))
+		if err != nil {
+			t.Fatal("Error setting value:", err)
+		}
+	}
+
+	err = ns.Set("invalid", []byte("not json"))
+	if err != ErrInvalidValue {
+		t.Error("Invalid JSON must be rejected:", err)
+	}
+
+	keys, err := ns.List("users/")
+	if err != nil || len(keys) != 2 || keys[0] != "users/1" || keys[1] != "users/2" {
+		t.Error("Unexpected keys:", keys, err)
+	}
+
+	err = ns.Delete("users/1")
+	if err != nil {
+		t.Fatal("Error deleting value:", err)
+	}
+
+	// i dati devono sopravvivere alla riapertura
+	store.Close()
+	store, err = Open(filename)
+	if err != nil {
+		t.Fatal("Error reopening store:", err)
+	}
+	defer store.Close()
+
+	value, ok, err := store.Get("Scope", "users/2")
+	if err != nil || !ok || string(value) != `{"key":"users/2"}` {
+		t.Error("Unexpected value:", string(value), ok, err)
+	}
+
+	_, ok, _ = store.Get("Scope", "users/1")
+	if ok {
+		t.Error("Deleted key still present")
+	}
+
+	_, ok, _ = store.Get("Other", "users/2")
+	if ok {
+		t.Error("Namespaces must be separated")
+	}
+}
+
+func TestHandle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal("Error creating temp dir:", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := Open(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatal("Error opening store:", err)
+	}
+	defer store.Close()
+
+	type note struct {
+		Text string
+	}
+
+	h := NewHandle(NewNamespace(store, "Notes"))
+
+	err = h.User(1).Set("note/1", note{"first"})
+	if err != nil {
+		t.Fatal("Error setting value:", err)
+	}
+	h.User(1).Set("note/2", note{"second"})
+	h.User(2).Set("note/1", note{"other user"})
+	h.Chat(-100).Set("note/1", note{"chat"})
+
+	n := note{}
+	ok, err := h.User(1).Get("note/2", &n)
+	if err != nil || !ok || n.Text != "second" {
+		t.Error("Unexpected value:", n, ok, err)
+	}
+
+	ok, _ = h.User(3).Get("note/1", &n)
+	if ok {
+		t.Error("Users must be separated")
+	}
+
+	keys, err := h.User(1).List("note/")
+	if err != nil || len(keys) != 2 || keys[0] != "note/1" || keys[1] != "note/2" {
+		t.Error("Unexpected keys:", keys, err)
+	}
+
+	keys, _ = h.List("chat/")
+	if len(keys) != 1 || keys[0] != "chat/-100/note/1" {
+		t.Error("Unexpected chat keys:", keys)
+	}
+
+	// le chiavi dell'intero namespace non devono sovrapporsi a quelle di utenti e chat
+	for _, key := range []string{"user/1/note/1", "chat/-100/note/1"} {
+		if err = h.Set(key, note{"root"}); err != ErrReservedKey {
+			t.Error("Reserved key set:", key, err)
+		}
+		if err = h.Delete(key); err != ErrReservedKey {
+			t.Error("Reserved key deleted:", key, err)
+		}
+	}
+	ok, _ = h.User(1).Get("note/1", &n)
+	if !ok || n.Text != "first" {
+		t.Error("User value overwritten:", n)
+	}
+
+	err = h.Set("users/1", note{"root"})
+	if err != nil {
+		t.Error("Error setting root value:", err)
+	}
+	keys, _ = h.User(1).List("")
+	if len(keys) != 2 {
+		t.Error("Root key visible to the user:", keys)
+	}
+
+	h.User(1).Delete("note/1")
+	keys, _ = h.User(1).List("")
+	if len(keys) != 1 {
+		t.Error("Deleted key still present:", keys)
+	}
+}
+
+func TestStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal("Error creating temp dir:", err)
+	}
+	defer os.RemoveAll(dir)
+
+	testStore(t, filepath.Join(dir, "state.json"))
+	testStore(t, filepath.Join(dir, "state.db"))
+}
+
+func TestJSONFileSaveError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal("Error creating temp dir:", err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "state.json")
+	store, err := OpenJSONFile(filename)
+	if err != nil {
+		t.Fatal("Error opening store:", err)
+	}
+
+	err = store.Set("Scope", "a", []byte(`1`))
+	if err != nil {
+		t.Fatal("Error setting value:", err)
+	}
+
+	// il file non è più scrivibile: le modifiche non devono restare in memoria
+	os.Remove(filename)
+	os.Mkdir(filename, 0700)
+
+	if err = store.Set("Scope", "a", []byte(`2`)); err == nil {
+		t.Fatal("Set succeeded without saving")
+	}
+	if err = store.Set("Scope", "b", []byte(`3`)); err == nil {
+		t.Fatal("Set succeeded without saving")
+	}
+	if err = store.Set("Other", "c", []byte(`4`)); err == nil {
+		t.Fatal("Set succeeded without saving")
+	}
+	if err = store.Delete("Scope", "a"); err == nil {
+		t.Fatal("Delete succeeded without saving")
+	}
+
+	value, ok, _ := store.Get("Scope", "a")
+	if !ok || string(value) != "1" {
+		t.Error("Value not rolled back:", string(value), ok)
+	}
+	if keys, _ := store.List("Scope", ""); len(keys) != 1 {
+		t.Error("Unexpected keys after failed saves:", keys)
+	}
+	if _, ok := store.data["Other"]; ok {
+		t.Error("Namespace created by failed save")
+	}
+}
@@ -2,6 +2,6 @@ module modulo.srl/testBot
 
 go 1.14
 
-replace modulo.srl/AssistantBot => ./../
+replace github.com/marcozaccari/AssistantBot => ./../
 
-require modulo.srl/AssistantBot v0.0.0-00010101000000-000000000000
+require github.com/marcozaccari/AssistantBot v0.0.0-00010101000000-000000000000
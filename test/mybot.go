@@ -1,29 +1,70 @@
 package main
 
 import (
-	"log"
+	"flag"
 	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
-	"modulo.srl/AssistantBot/bot"
+	"github.com/marcozaccari/AssistantBot/bot"
+	"github.com/marcozaccari/AssistantBot/storage"
 )
 
-var tbot *bot.Bot
-
 type myConfig struct {
 	Foo int
 	Bar string
 }
 
 type myProcessor struct {
+	bot.StubProcessor
+
 	config myConfig
+
+	bot     *bot.Bot
+	storage *storage.Handle
+}
+
+// Init - invocata dopo il caricamento della configurazione
+func (p *myProcessor) Init(b *bot.Bot) error {
+	p.bot = b
+
+	var err error
+	p.storage, err = b.Storage("myscope")
+	return err
+}
+
+func (p *myProcessor) Start() error {
+	log.Println("myProcessor started")
+	return nil
+}
+
+func (p *myProcessor) Stop() error {
+	log.Println("myProcessor stopped")
+	return nil
+}
+
+func (p *myProcessor) Version() string {
+	return "1.0.0"
 }
 
 func (p *myProcessor) ProcessCommand(handler bot.MessageHandler, command string, params []string) (bool, error) {
 	if command == "hello" {
-		message := fmt.Sprintln("Hello World!", p.config)
+		// conteggio dei saluti di ogni utente
+		var count int
+		userStorage := p.storage.User(handler.UserID)
+		userStorage.Get("hello", &count)
+		count++
+		userStorage.Set("hello", count)
 
-		opt := tbot.NewMessageResponseOpt()
-		tbot.SendMessageResponse(handler, message, opt)
+		var message string
+		p.bot.ViewConfig(func() {
+			message = fmt.Sprintln(p.bot.T(handler.Language, "myscope.hello"), p.config, "count", count)
+		})
+
+		opt := p.bot.NewMessageResponseOpt()
+		p.bot.SendMessageResponse(handler, message, opt)
 
 		return true, nil
 	}
@@ -34,8 +75,8 @@ func (p *myProcessor) ProcessCommand(handler bot.MessageHandler, command string,
 func (p *myProcessor) ProcessMessage(handler bot.MessageHandler, text string) (bool, error) {
 	message := "ECHO: " + text
 
-	opt := tbot.NewMessageResponseOpt()
-	tbot.SendMessageResponse(handler, message, opt)
+	opt := p.bot.NewMessageResponseOpt()
+	p.bot.SendMessageResponse(handler, message, opt)
 
 	return true, nil
 }
@@ -44,13 +85,39 @@ func (p *myProcessor) Help() string {
 	return "/hello  Print hello world\n"
 }
 
+// Commands - comandi per il menu di Telegram (in alternativa vengono ricavati da Help)
+func (p *myProcessor) Commands() []bot.CommandInfo {
+	return []bot.CommandInfo{
+		{
+			Command:      "hello",
+			Description:  "Print hello world",
+			Descriptions: map[string]string{"it": "Stampa hello world"},
+		},
+	}
+}
+
 func main() {
 	processor := myProcessor{}
 
-	tbot = bot.NewBot("settings.bot.json", true, false)
+	tbot := bot.NewBot("settings.bot.json", true, false)
 
 	tbot.RegisterProcessor("myscope", &processor, &processor.config)
 
+	tbot.RegisterTranslations("en", map[string]string{"myscope.hello": "Hello World!"})
+	tbot.RegisterTranslations("it", map[string]string{"myscope.hello": "Ciao mondo!"})
+
+	// es. -set Bot.SecureToken=xxx (in alternativa ASSISTANTBOT_BOT_SECURETOKEN=xxx)
+	flag.Var(tbot.ConfigOverrideFlag(), "set", "override a setting (scope.Field=value)")
+	flag.Parse()
+
+	// arresto pulito con Ctrl+C o SIGTERM
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		tbot.Stop()
+	}()
+
 	err := tbot.Do()
 	if err != nil {
 		log.Println("ERROR:", err.Error())